@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"github.com/unklejo/swpr.drone/generated"
 	"github.com/unklejo/swpr.drone/handler"
@@ -13,11 +14,13 @@ import (
 
 func main() {
 	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
 
 	var server generated.ServerInterface = newServer()
 
 	generated.RegisterHandlers(e, server)
 	e.Use(middleware.Logger())
+	e.Use(handler.RequestTimeout(requestTimeout()))
 	e.Logger.Fatal(e.Start(":1323"))
 }
 
@@ -31,3 +34,18 @@ func newServer() *handler.Server {
 	}
 	return handler.NewServer(opts)
 }
+
+// requestTimeout reads the deadline of the requests from REQUEST_TIMEOUT, a
+// duration such as "10s", 30 seconds by default.
+func requestTimeout() time.Duration {
+	value := os.Getenv("REQUEST_TIMEOUT")
+	if value == "" {
+		return 30 * time.Second
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		panic("REQUEST_TIMEOUT must be a positive duration, e.g. 10s")
+	}
+	return timeout
+}
@@ -16,8 +16,14 @@ CREATE TABLE IF NOT EXISTS estates (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     width INTEGER NOT NULL,
     length INTEGER NOT NULL,
+    -- Optional geo-reference: coordinates of the center of plot (1, 1) and
+    -- direction of the rows in degrees clockwise from the true north
+    origin_latitude DOUBLE PRECISION CHECK (origin_latitude BETWEEN -90 AND 90),
+    origin_longitude DOUBLE PRECISION CHECK (origin_longitude BETWEEN -180 AND 180),
+    bearing DOUBLE PRECISION CHECK (bearing >= 0 AND bearing < 360),
     created_at TIMESTAMPTZ DEFAULT NOW(),
-	updated_at TIMESTAMPTZ DEFAULT NOW()
+	updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK ((origin_latitude IS NULL) = (origin_longitude IS NULL) AND (origin_latitude IS NULL) = (bearing IS NULL))
 );
 
 -- Table to store tree information within estates
@@ -29,16 +35,67 @@ CREATE TABLE IF NOT EXISTS trees (
     height INTEGER NOT NULL,
     created_at TIMESTAMPTZ DEFAULT NOW(),
 	updated_at TIMESTAMPTZ DEFAULT NOW(),
-    UNIQUE (estate_id, x_coordinate, y_coordinate)
+    -- Removed trees are kept for the stats of the past, see idx_trees_plot
+    deleted_at TIMESTAMPTZ
+);
+
+-- Table to store the height history of the trees. The latest measurement
+-- of a tree is its current height, also stored on the tree.
+CREATE TABLE IF NOT EXISTS tree_measurements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tree_id UUID NOT NULL REFERENCES trees(id) ON DELETE CASCADE,
+    height INTEGER NOT NULL,
+    measured_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Where the measurement comes from: planting, import, correction, or
+    -- whatever the surveyors report
+    source VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Table to store the named blocks of the estates, as rectangles of plots
+-- from (x1, y1) to (x2, y2). The zones of an estate do not overlap, which is
+-- checked while holding a lock on the estate.
+CREATE TABLE IF NOT EXISTS zones (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    estate_id UUID NOT NULL REFERENCES estates(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    x1 INTEGER NOT NULL CHECK (x1 >= 1),
+    y1 INTEGER NOT NULL CHECK (y1 >= 1),
+    x2 INTEGER NOT NULL,
+    y2 INTEGER NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (estate_id, name),
+    CHECK (x1 <= x2 AND y1 <= y2)
 );
 
 -- Indexes to improve query performance
-CREATE INDEX IF NOT EXISTS idx_trees_estate_id ON trees (estate_id);
+-- Serves the lookups by estate as well as by region of an estate, removed
+-- trees included
+CREATE INDEX IF NOT EXISTS idx_trees_estate_id_plot ON trees (estate_id, x_coordinate, y_coordinate);
+-- A plot holds one tree at a time, it can be planted again once removed
+CREATE UNIQUE INDEX IF NOT EXISTS idx_trees_plot ON trees (estate_id, x_coordinate, y_coordinate) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_estates_created_at ON estates (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_tree_measurements_tree_id ON tree_measurements (tree_id, measured_at);
 
--- Table to store drone plan
+-- Table to store the drone plan of the default route over an estate.
+-- A row is created with the estate and updated along with every tree change.
+-- It is dropped when the estate is resized or the drone config changes, and
+-- recomputed on demand.
 CREATE TABLE drone_plans (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     estate_id UUID REFERENCES estates(id) ON DELETE CASCADE,
-    distance INTEGER NOT NULL,
+    distance BIGINT NOT NULL, -- Exceeds INTEGER on the largest estates
     UNIQUE (estate_id)
 );
+
+-- Table to store the drone flight parameters of an estate, in meters.
+-- Estates without a row use the default parameters.
+CREATE TABLE IF NOT EXISTS drone_configs (
+    estate_id UUID PRIMARY KEY REFERENCES estates(id) ON DELETE CASCADE,
+    plot_size INTEGER NOT NULL CHECK (plot_size > 0),
+    clearance INTEGER NOT NULL CHECK (clearance >= 0),
+    takeoff_altitude INTEGER NOT NULL DEFAULT 0 CHECK (takeoff_altitude >= 0),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
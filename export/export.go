@@ -0,0 +1,200 @@
+// Package export streams the trees of an estate as files, for analysis
+// outside of the service.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/unklejo/swpr.drone/repository"
+)
+
+// Formats the trees can be exported to.
+const (
+	FormatCSV = "csv"
+	// FormatNDJSON is one JSON object per line.
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// parquetRowGroupSize bounds the trees held in memory while writing a
+// Parquet file, which is written one row group at a time.
+const parquetRowGroupSize = 10000
+
+var formats = map[string]struct {
+	contentType string
+	extension   string
+	// aliases are the other media types accepted for the format
+	aliases []string
+}{
+	FormatCSV:     {contentType: "text/csv; charset=utf-8", extension: "csv"},
+	FormatNDJSON:  {contentType: "application/x-ndjson", extension: "ndjson", aliases: []string{"application/jsonl", "application/x-jsonlines"}},
+	FormatParquet: {contentType: "application/vnd.apache.parquet", extension: "parquet", aliases: []string{"application/x-parquet"}},
+}
+
+// ContentType returns the media type and the file extension of an export
+// format, and false when the format is not supported.
+func ContentType(format string) (contentType, extension string, ok bool) {
+	f, ok := formats[format]
+	return f.contentType, f.extension, ok
+}
+
+// Negotiate returns the first format of an Accept header that is supported,
+// CSV when the header is empty or accepts anything, and false when none is.
+func Negotiate(accept string) (format string, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		return FormatCSV, true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		if mediaType == "*/*" || mediaType == "text/*" {
+			return FormatCSV, true
+		}
+
+		for format, f := range formats {
+			contentType, _, _ := mime.ParseMediaType(f.contentType)
+			if mediaType == contentType {
+				return format, true
+			}
+			for _, alias := range f.aliases {
+				if mediaType == alias {
+					return format, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// encoder writes trees in a file format, one at a time.
+type encoder interface {
+	tree(tree repository.Tree) error
+	// close writes what is left of the file, without closing the writer
+	close() error
+}
+
+// Export writes the trees produced by trees to w in the given format, without
+// holding them all in memory. It returns ErrUnknownFormat when the format is
+// not supported.
+func Export(w io.Writer, format string, trees func(visit func(tree repository.Tree) error) error) error {
+	var enc encoder
+	switch format {
+	case FormatCSV:
+		enc = newCSVEncoder(w)
+	case FormatNDJSON:
+		enc = &ndjsonEncoder{encoder: json.NewEncoder(w)}
+	case FormatParquet:
+		enc = newParquetEncoder(w)
+	default:
+		return ErrUnknownFormat
+	}
+
+	if err := trees(enc.tree); err != nil {
+		return err
+	}
+	return enc.close()
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	record []string
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	e := &csvEncoder{writer: csv.NewWriter(w), record: make([]string, 6)}
+	e.writer.Write([]string{"id", "x", "y", "height", "created_at", "updated_at"})
+	return e
+}
+
+func (e *csvEncoder) tree(tree repository.Tree) error {
+	e.record[0] = tree.Id
+	e.record[1] = strconv.Itoa(tree.X)
+	e.record[2] = strconv.Itoa(tree.Y)
+	e.record[3] = strconv.Itoa(tree.Height)
+	e.record[4] = tree.CreatedAt.UTC().Format(time.RFC3339Nano)
+	e.record[5] = tree.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	return e.writer.Write(e.record)
+}
+
+func (e *csvEncoder) close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonEncoder) tree(tree repository.Tree) error {
+	return e.encoder.Encode(tree)
+}
+
+func (e *ndjsonEncoder) close() error {
+	return nil
+}
+
+// parquetTree is the schema of the Parquet files. Timestamps are in
+// milliseconds since the epoch.
+type parquetTree struct {
+	Id        string `parquet:"id"`
+	X         int32  `parquet:"x"`
+	Y         int32  `parquet:"y"`
+	Height    int32  `parquet:"height"`
+	CreatedAt int64  `parquet:"created_at,timestamp(millisecond)"`
+	UpdatedAt int64  `parquet:"updated_at,timestamp(millisecond)"`
+}
+
+// parquetEncoder buffers a row group worth of trees before handing them to
+// the Parquet writer, which writes the row group out.
+type parquetEncoder struct {
+	writer *parquet.GenericWriter[parquetTree]
+	rows   []parquetTree
+}
+
+func newParquetEncoder(w io.Writer) *parquetEncoder {
+	return &parquetEncoder{
+		writer: parquet.NewGenericWriter[parquetTree](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		rows:   make([]parquetTree, 0, parquetRowGroupSize),
+	}
+}
+
+func (e *parquetEncoder) tree(tree repository.Tree) error {
+	e.rows = append(e.rows, parquetTree{
+		Id:        tree.Id,
+		X:         int32(tree.X),
+		Y:         int32(tree.Y),
+		Height:    int32(tree.Height),
+		CreatedAt: tree.CreatedAt.UnixMilli(),
+		UpdatedAt: tree.UpdatedAt.UnixMilli(),
+	})
+
+	if len(e.rows) < parquetRowGroupSize {
+		return nil
+	}
+	return e.flush()
+}
+
+func (e *parquetEncoder) flush() error {
+	if _, err := e.writer.Write(e.rows); err != nil {
+		return err
+	}
+	e.rows = e.rows[:0]
+	return e.writer.Flush()
+}
+
+func (e *parquetEncoder) close() error {
+	if err := e.flush(); err != nil {
+		return err
+	}
+	return e.writer.Close()
+}
@@ -0,0 +1,98 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/unklejo/swpr.drone/repository"
+)
+
+var plantedAt = time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+
+func testTrees(visit func(tree repository.Tree) error) error {
+	trees := []repository.Tree{
+		{Id: "a", X: 1, Y: 1, Height: 10, CreatedAt: plantedAt, UpdatedAt: plantedAt},
+		{Id: "b", X: 2, Y: 1, Height: 20, CreatedAt: plantedAt, UpdatedAt: plantedAt.Add(time.Hour)},
+	}
+	for _, tree := range trees {
+		if err := visit(tree); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestNegotiate(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"":                     FormatCSV,
+		"*/*":                  FormatCSV,
+		"text/csv":             FormatCSV,
+		"application/x-ndjson": FormatNDJSON,
+		"application/jsonl":    FormatNDJSON,
+		"application/json, application/x-ndjson;q=0.9": FormatNDJSON,
+		"application/vnd.apache.parquet":               FormatParquet,
+	} {
+		format, ok := Negotiate(accept)
+		assert.True(t, ok, accept)
+		assert.Equal(t, expected, format, accept)
+	}
+
+	_, ok := Negotiate("application/json")
+	assert.False(t, ok)
+}
+
+func TestExport_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Export(&buf, FormatCSV, testTrees))
+
+	assert.Equal(t, "id,x,y,height,created_at,updated_at\n"+
+		"a,1,1,10,2024-03-01T08:30:00Z,2024-03-01T08:30:00Z\n"+
+		"b,2,1,20,2024-03-01T08:30:00Z,2024-03-01T09:30:00Z\n", buf.String())
+}
+
+func TestExport_NDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Export(&buf, FormatNDJSON, testTrees))
+
+	assert.Equal(t, `{"id":"a","x":1,"y":1,"height":10,"created_at":"2024-03-01T08:30:00Z","updated_at":"2024-03-01T08:30:00Z"}`+"\n"+
+		`{"id":"b","x":2,"y":1,"height":20,"created_at":"2024-03-01T08:30:00Z","updated_at":"2024-03-01T09:30:00Z"}`+"\n", buf.String())
+}
+
+func TestExport_Parquet(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Export(&buf, FormatParquet, testTrees))
+
+	rows, err := parquet.Read[parquetTree](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.Equal(t, []parquetTree{
+		{Id: "a", X: 1, Y: 1, Height: 10, CreatedAt: plantedAt.UnixMilli(), UpdatedAt: plantedAt.UnixMilli()},
+		{Id: "b", X: 2, Y: 1, Height: 20, CreatedAt: plantedAt.UnixMilli(), UpdatedAt: plantedAt.Add(time.Hour).UnixMilli()},
+	}, rows)
+}
+
+func TestExport_ParquetRowGroups(t *testing.T) {
+	count := parquetRowGroupSize + 1
+	trees := func(visit func(tree repository.Tree) error) error {
+		for i := 0; i < count; i++ {
+			if err := visit(repository.Tree{X: i + 1, Y: 1, Height: 1, CreatedAt: plantedAt, UpdatedAt: plantedAt}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, Export(&buf, FormatParquet, trees))
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(count), file.NumRows())
+	assert.Len(t, file.RowGroups(), 2)
+}
+
+func TestExport_UnknownFormat(t *testing.T) {
+	assert.ErrorIs(t, Export(&bytes.Buffer{}, "xlsx", testTrees), ErrUnknownFormat)
+}
@@ -1,35 +1,91 @@
 package handler
 
 import (
-	"database/sql"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 
-	"github.com/lib/pq"
+	"github.com/unklejo/swpr.drone/export"
+	"github.com/unklejo/swpr.drone/generated"
+	"github.com/unklejo/swpr.drone/planner"
+	"github.com/unklejo/swpr.drone/repository"
+)
+
+const (
+	defaultPathLimit = 1000
+	maxPathLimit     = 10000
+	maxMissions      = 10000
+
+	defaultEstateLimit = 20
+	maxEstateLimit     = 100
+
+	defaultTreeLimit = 100
+	maxTreeLimit     = 1000
+
+	maxTreeHeight = 30
+
+	maxBulkTrees = 10000
+	// maxBulkBytes is far more than 10000 trees take, whatever the format
+	maxBulkBytes = 4 << 20
+
+	defaultMeasurementSource = "survey"
+	maxMeasurementSource     = 50
+
+	defaultBucketWidth  = 5
+	maxStatsPercentiles = 20
+
+	maxZoneName = 100
+
+	defaultPortfolioTop = 5
+	maxPortfolioTop     = 20
+	maxPortfolioEstates = 100
+
+	// Rows laid eastward unless told otherwise
+	defaultBearing = 90
+
+	maxPlotSize        = 100
+	maxClearance       = 100
+	maxTakeoffAltitude = 500
 )
 
 // 1. Handler for POST `/estate` endpoint
 func (s *Server) PostEstate(ctx echo.Context) error {
 	var request struct {
-		Width  int `json:"width"`
-		Length int `json:"length"`
+		Width     int      `json:"width"`
+		Length    int      `json:"length"`
+		Latitude  *float64 `json:"latitude"`
+		Longitude *float64 `json:"longitude"`
+		Bearing   *float64 `json:"bearing"`
 	}
 
 	if err := ctx.Bind(&request); err != nil {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return invalidBody(err)
 	}
 
 	if request.Width <= 0 || request.Length <= 0 {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Width and Length must be greater than 0"})
+		return invalid("Width and Length must be greater than 0", "width", "length")
 	}
 
-	id, err := s.Repository.CreateEstate(request.Width, request.Length)
+	// The geo-reference is optional, but latitude and longitude go together
+	geo, err := geoReference(nil, request.Latitude, request.Longitude, request.Bearing)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.Repository.CreateEstate(ctx.Request().Context(), request.Width, request.Length, geo)
 
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create estate"})
+		return serverError(ctx, err, "Failed to create estate")
 	}
 
 	return ctx.JSON(http.StatusCreated, map[string]string{"id": id})
@@ -45,79 +101,1503 @@ func (s *Server) PostEstateIdTree(ctx echo.Context, uuid uuid.UUID) error {
 	}
 
 	if err := ctx.Bind(&request); err != nil {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
+		return invalidBody(err)
 	}
 
 	// Tree height is 0 or lower
 	if request.Height < 0 || request.Height >= 30 {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Height must be within 1 to 30 meters"})
+		return invalid("Height must be within 1 to 30 meters", "height")
 	}
 
 	// Check the estate exist or not
-	estate, err := s.Repository.GetEstateById(estateId)
+	estate, err := s.estate(ctx, estateId)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Estate not found"})
-		}
+		return err
 	}
 
 	// Coordinates out of bounds from estate's plot
-	if request.X <= 0 || request.Y <= 0 || request.X > estate.Width || request.Y > estate.Length {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Coordinates out of bounds"})
+	if !inEstate(estate, request.X, request.Y) {
+		return newProblem(http.StatusBadRequest, codeOutOfBounds, "Coordinates out of bounds")
 	}
 
+	// AddTree checks the plot against the estate again, which may have been
+	// resized or deleted since
+	id, err := s.Repository.AddTree(ctx.Request().Context(), estateId, request.X, request.Y, request.Height)
+
 	// Error handling regarding database and foreign key
-	id, err := s.Repository.AddTree(estateId, request.X, request.Y, request.Height)
 	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEstateNotFound):
+			return newProblem(http.StatusNotFound, codeEstateNotFound, "Estate not found")
 		// Tree already exists in the plot (handling racing condition)
-		if err, ok := err.(*pq.Error); ok && err.Code == "23505" { // Unique violation
-			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Plot already has a tree"})
+		case errors.Is(err, repository.ErrPlotOccupied):
+			return newProblem(http.StatusBadRequest, codePlotOccupied, "Plot already has a tree")
+		case errors.Is(err, repository.ErrPlotOutOfBounds):
+			return newProblem(http.StatusBadRequest, codeOutOfBounds, "Coordinates out of bounds")
 		}
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to add tree"})
+		return serverError(ctx, err, "Failed to add tree")
 	}
 
 	return ctx.JSON(http.StatusCreated, map[string]string{"id": id})
 }
 
 // 3. Handler for GET `/estate/:id/stats` endpoint
-func (s *Server) GetEstateIdStats(ctx echo.Context, uuid uuid.UUID) error {
+func (s *Server) GetEstateIdStats(ctx echo.Context, uuid uuid.UUID, params generated.GetEstateIdStatsParams) error {
 	estateId := ctx.Param("id")
 
 	// Check the estate exist or not, just like in AddTree
-	_, err := s.Repository.GetEstateById(estateId)
+	estate, err := s.estate(ctx, estateId)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Estate not found"})
-		}
+		return err
+	}
+
+	options, err := statsOptions(params.Include, params.Percentiles, params.BucketWidth)
+	if err != nil {
+		return invalid(err.Error())
+	}
+
+	// The stats of a block of the estate, checked like the plot of a new tree
+	bounds, ok := boundsParams(params.X1, params.Y1, params.X2, params.Y2)
+	if !ok {
+		return invalid("x1, y1, x2 and y2 must be set together, from 1 and with x1 <= x2 and y1 <= y2", "x1", "y1", "x2", "y2")
+	}
+	if bounds != nil && !boundsInEstate(estate, *bounds) {
+		return newProblem(http.StatusBadRequest, codeOutOfBounds, "Coordinates out of bounds")
 	}
+	options.Bounds = bounds
 
-	stats, err := s.Repository.GetEstateStatsById(estateId)
+	return s.respondStats(ctx, estateId, params.AsOf, options)
+}
+
+// respondStats responds with the stats of an estate, as of the as_of
+// parameter when set.
+func (s *Server) respondStats(ctx echo.Context, estateId string, asOfParam *string, options repository.StatsOptions) error {
+	// Past stats are rebuilt from the height history
+	var stats repository.EstateStats
+	var err error
+	if asOfParam != nil {
+		asOf, ok := parseAsOf(*asOfParam)
+		if !ok {
+			return invalid("as_of must be a date or a date-time", "as_of")
+		}
+		stats, err = s.Repository.GetEstateStatsAsOf(ctx.Request().Context(), estateId, asOf, options)
+	} else {
+		stats, err = s.Repository.GetEstateStatsById(ctx.Request().Context(), estateId, options)
+	}
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve estate stats"})
+		return serverError(ctx, err, "Failed to retrieve estate stats")
 	}
 
 	return ctx.JSON(http.StatusOK, stats)
 }
 
 // 4. Handler for GET `/estate/:id/drone-plan` endpoint
-func (s *Server) GetEstateIdDronePlan(ctx echo.Context, uuid uuid.UUID) error {
+func (s *Server) GetEstateIdDronePlan(ctx echo.Context, uuid uuid.UUID, params generated.GetEstateIdDronePlanParams) error {
 	estateId := ctx.Param("id")
 
+	if params.MaxDistance != nil && *params.MaxDistance <= 0 {
+		return invalid("max_distance must be greater than 0", "max_distance")
+	}
+
+	traversal, cacheable, err := dronePlanTraversal(params)
+	if err != nil {
+		return invalid("Unsupported pattern or corner", "pattern", "corner")
+	}
+
+	smooth := params.Mode != nil && *params.Mode == generated.Smooth
+	if params.Mode != nil && !smooth && *params.Mode != generated.Standard {
+		return invalid("Unsupported mode", "mode")
+	}
+	if smooth && params.MaxDistance != nil {
+		return invalid("max_distance cannot be combined with the smooth mode", "max_distance", "mode")
+	}
+
+	format := dronePlanFormat(ctx, params)
+	if format != "" {
+		if _, _, ok := planner.ContentType(format); !ok {
+			return invalid("Unsupported format", "format")
+		}
+		if params.MaxDistance != nil || smooth {
+			return invalid("max_distance and the smooth mode cannot be combined with an export format", "max_distance", "mode")
+		}
+	}
+
 	// Check the estate exist or not, just like in AddTree
-	_, err := s.Repository.GetEstateById(estateId)
+	estate, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	if format != "" {
+		return s.exportDronePlan(ctx, estateId, estate, traversal, format)
+	}
+
+	// A battery limited plan depends on the limit, so it is never cached
+	if params.MaxDistance != nil {
+		p, err := newPlanner(ctx.Request().Context(), s.Repository, estateId, estate, traversal)
+		if err != nil {
+			return serverError(ctx, err, "Failed to retrieve drone plans")
+		}
+
+		distance, rest, err := p.DistanceWithLimit(ctx.Request().Context(), *params.MaxDistance)
+		if err != nil {
+			return serverError(ctx, err, "Failed to plan drone flight")
+		}
+		return ctx.JSON(http.StatusOK, repository.DronePlan{
+			Distance: distance,
+			Rest:     &repository.RestPoint{X: rest.X, Y: rest.Y},
+		})
+	}
+
+	var p *planner.Planner
+	if !cacheable || smooth {
+		if p, err = newPlanner(ctx.Request().Context(), s.Repository, estateId, estate, traversal); err != nil {
+			return serverError(ctx, err, "Failed to retrieve drone plans")
+		}
+	}
+
+	// Only the default pattern is cached
+	var plan repository.DronePlan
+	if cacheable {
+		if plan, err = s.cachedDronePlan(ctx, estateId); err != nil {
+			return serverError(ctx, err, "Failed to retrieve drone plans")
+		}
+	} else {
+		plan = repository.DronePlan{Distance: p.Distance()}
+	}
+
+	// Report the naive distance next to the smooth one to quantify the savings
+	if smooth {
+		naiveDistance := plan.Distance
+		plan.NaiveDistance = &naiveDistance
+		plan.Distance = p.SmoothDistance()
+	}
+
+	return ctx.JSON(http.StatusOK, plan)
+}
+
+// cachedDronePlan returns the stored plan of the default pattern, computing
+// and storing it when the estate has none, e.g. after a drone config change.
+// The plan is computed and stored with the estate locked, so that no tree
+// write lands in between and leaves a stale plan behind: the writes either
+// wait for the plan to be stored and update it, or come first and are read.
+func (s *Server) cachedDronePlan(ctx echo.Context, estateId string) (repository.DronePlan, error) {
+	// Either a cache hit or a failure to read the cache
+	plan, err := s.Repository.GetDronePlanByEstateId(ctx.Request().Context(), estateId)
+	if !errors.Is(err, repository.ErrDronePlanNotFound) {
+		return plan, err
+	}
+
+	var saveErr error
+	err = s.Repository.WithTx(ctx.Request().Context(), repository.TxOptions{}, func(repo repository.RepositoryInterface) error {
+		estate, err := repo.LockEstate(ctx.Request().Context(), estateId)
+		if err != nil {
+			return err
+		}
+
+		// The plan may have been stored while waiting for the lock
+		plan, err = repo.GetDronePlanByEstateId(ctx.Request().Context(), estateId)
+		if !errors.Is(err, repository.ErrDronePlanNotFound) {
+			return err
+		}
+
+		p, err := newPlanner(ctx.Request().Context(), repo, estateId, estate, nil)
+		if err != nil {
+			return err
+		}
+
+		plan = repository.DronePlan{Distance: p.Distance()}
+		saveErr = repo.SaveDronePlan(ctx.Request().Context(), estateId, plan)
+		return saveErr
+	})
+
+	// A failed cache write only costs a recomputation on the next request
+	if saveErr != nil {
+		ctx.Logger().Errorf("failed to cache drone plan of estate %s: %v", estateId, saveErr)
+		return plan, nil
+	}
+	return plan, err
+}
+
+// 5. Handler for GET `/estate/:id/drone-plan/missions` endpoint
+func (s *Server) GetEstateIdDronePlanMissions(ctx echo.Context, uuid uuid.UUID, params generated.GetEstateIdDronePlanMissionsParams) error {
+	estateId := ctx.Param("id")
+
+	if params.BatteryCapacity <= 0 {
+		return invalid("battery_capacity must be greater than 0", "battery_capacity")
+	}
+
+	estate, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPlanner(ctx.Request().Context(), s.Repository, estateId, estate, nil)
+	if err != nil {
+		return serverError(ctx, err, "Failed to retrieve drone plans")
+	}
+
+	missions, err := p.Missions(ctx.Request().Context(), params.BatteryCapacity, maxMissions)
+	if err != nil {
+		switch {
+		case errors.Is(err, planner.ErrBatteryTooSmall):
+			return invalid("Battery capacity too small to cover a single plot", "battery_capacity")
+		case errors.Is(err, planner.ErrTooManySorties):
+			return invalid("Battery capacity too small to cover the estate in 10000 missions", "battery_capacity")
+		}
+		return serverError(ctx, err, "Failed to plan drone missions")
+	}
+
+	distance := 0
+	for _, mission := range missions {
+		distance += mission.Distance
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"distance": distance,
+		"missions": missions,
+	})
+}
+
+// 6. Handler for GET `/estate/:id/drone-plan/path` endpoint
+func (s *Server) GetEstateIdDronePlanPath(ctx echo.Context, uuid uuid.UUID, params generated.GetEstateIdDronePlanPathParams) error {
+	estateId := ctx.Param("id")
+
+	// The first page starts from the takeoff
+	cursor, limit := -1, defaultPathLimit
+	if params.Cursor != nil {
+		cursor = *params.Cursor
+		if cursor < 0 {
+			return invalid("cursor must be 0 or greater", "cursor")
+		}
+	}
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+	if limit <= 0 || limit > maxPathLimit {
+		return invalid("limit must be within 1 to 10000", "limit")
+	}
+
+	estate, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPlanner(ctx.Request().Context(), s.Repository, estateId, estate, nil)
+	if err != nil {
+		return serverError(ctx, err, "Failed to retrieve drone plans")
+	}
+
+	// Walk the path up to one waypoint past the page to know if there is a next one
+	waypoints := make([]planner.Waypoint, 0, limit)
+	hasNext, last := false, cursor
+	err = p.PathAfter(ctx.Request().Context(), cursor, func(waypoint planner.Waypoint, cursor int) bool {
+		if len(waypoints) == limit {
+			hasNext = true
+			return false
+		}
+		waypoints = append(waypoints, waypoint)
+		last = cursor
+		return true
+	})
+	if err != nil {
+		if errors.Is(err, planner.ErrInvalidCursor) {
+			return invalid("cursor is past the end of the path", "cursor")
+		}
+		return serverError(ctx, err, "Failed to plan drone path")
+	}
+
+	response := map[string]interface{}{"waypoints": waypoints}
+	if hasNext {
+		response["next_cursor"] = last
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// dronePlanTraversal returns the traversal requested through the pattern and
+// corner parameters, and whether it is the default one whose distance is
+// cached.
+func dronePlanTraversal(params generated.GetEstateIdDronePlanParams) (traversal planner.Traversal, cacheable bool, err error) {
+	pattern, corner := planner.PatternRows, planner.CornerSouthWest
+	if params.Pattern != nil {
+		pattern = string(*params.Pattern)
+	}
+	if params.Corner != nil {
+		corner = string(*params.Corner)
+	}
+
+	traversal, err = planner.NewTraversal(pattern, corner)
+	return traversal, pattern == planner.PatternRows && corner == planner.CornerSouthWest, err
+}
+
+// dronePlanFormat returns the export format requested through the format
+// parameter or the Accept header, or an empty string for the JSON summary.
+func dronePlanFormat(ctx echo.Context, params generated.GetEstateIdDronePlanParams) string {
+	if params.Format != nil {
+		if *params.Format == generated.Json {
+			return ""
+		}
+		return string(*params.Format)
+	}
+
+	accept := ctx.Request().Header.Get(echo.HeaderAccept)
+	switch {
+	case strings.Contains(accept, "application/geo+json"):
+		return planner.FormatGeoJSON
+	case strings.Contains(accept, "application/vnd.google-earth.kml+xml"):
+		return planner.FormatKML
+	}
+	return ""
+}
+
+// exportDronePlan streams the flight path of the estate as a file.
+func (s *Server) exportDronePlan(ctx echo.Context, estateId string, estate repository.Estate, traversal planner.Traversal, format string) error {
+	if estate.GeoReference == nil {
+		return newProblem(http.StatusBadRequest, codeNoGeoReference, "Estate has no geo-reference to export the drone plan")
+	}
+
+	p, err := newPlanner(ctx.Request().Context(), s.Repository, estateId, estate, traversal)
+	if err != nil {
+		return serverError(ctx, err, "Failed to retrieve drone plans")
+	}
+
+	contentType, extension, _ := planner.ContentType(format)
+	ref := planner.GeoReference{
+		Latitude:  estate.GeoReference.Latitude,
+		Longitude: estate.GeoReference.Longitude,
+		Bearing:   estate.GeoReference.Bearing,
+	}
+
+	stream(ctx)
+	ctx.Response().Header().Set(echo.HeaderContentType, contentType)
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="drone-plan-%s.%s"`, estateId, extension))
+	ctx.Response().WriteHeader(http.StatusOK)
+
+	return planner.Export(ctx.Response(), format, ref, func(visit func(waypoint planner.Waypoint) bool) error {
+		return p.Path(ctx.Request().Context(), visit)
+	})
+}
+
+// 7. Handler for GET `/estate/:id/drone-plan/patterns` endpoint
+func (s *Server) GetEstateIdDronePlanPatterns(ctx echo.Context, uuid uuid.UUID) error {
+	estateId := ctx.Param("id")
+
+	estate, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	opts, err := plannerOptions(ctx.Request().Context(), s.Repository, estateId, estate)
+	if err != nil {
+		return serverError(ctx, err, "Failed to retrieve drone plans")
+	}
+
+	patterns := planner.ComparePatterns(opts)
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"recommended": patterns[0],
+		"patterns":    patterns,
+	})
+}
+
+// 8. Handler for GET `/estate/:id/drone-config` endpoint
+func (s *Server) GetEstateIdDroneConfig(ctx echo.Context, uuid uuid.UUID) error {
+	estateId := ctx.Param("id")
+
+	_, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	config, err := droneConfig(ctx.Request().Context(), s.Repository, estateId)
+	if err != nil {
+		return serverError(ctx, err, "Failed to retrieve drone config")
+	}
+
+	return ctx.JSON(http.StatusOK, config)
+}
+
+// 9. Handler for PUT `/estate/:id/drone-config` endpoint
+func (s *Server) PutEstateIdDroneConfig(ctx echo.Context, uuid uuid.UUID) error {
+	estateId := ctx.Param("id")
+	var request struct {
+		PlotSize        *int `json:"plot_size"`
+		Clearance       *int `json:"clearance"`
+		TakeoffAltitude *int `json:"takeoff_altitude"`
+	}
+
+	if err := ctx.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+
+	// Parameters left out fall back to their default
+	config := defaultDroneConfig()
+	if request.PlotSize != nil {
+		config.PlotSize = *request.PlotSize
+	}
+	if request.Clearance != nil {
+		config.Clearance = *request.Clearance
+	}
+	if request.TakeoffAltitude != nil {
+		config.TakeoffAltitude = *request.TakeoffAltitude
+	}
+
+	if config.PlotSize < 1 || config.PlotSize > maxPlotSize {
+		return invalid("Plot size must be within 1 to 100 meters", "plot_size")
+	}
+	if config.Clearance < 0 || config.Clearance > maxClearance {
+		return invalid("Clearance must be within 0 to 100 meters", "clearance")
+	}
+	if config.TakeoffAltitude < 0 || config.TakeoffAltitude > maxTakeoffAltitude {
+		return invalid("Takeoff altitude must be within 0 to 500 meters", "takeoff_altitude")
+	}
+
+	_, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Repository.SaveDroneConfig(ctx.Request().Context(), estateId, config); err != nil {
+		return serverError(ctx, err, "Failed to save drone config")
+	}
+
+	return ctx.JSON(http.StatusOK, config)
+}
+
+// 10. Handler for GET `/estate` endpoint
+func (s *Server) GetEstate(ctx echo.Context, params generated.GetEstateParams) error {
+	offset, limit := 0, defaultEstateLimit
+	if params.Offset != nil {
+		offset = *params.Offset
+	}
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+	if offset < 0 || limit <= 0 || limit > maxEstateLimit {
+		return invalid("offset must be 0 or greater and limit within 1 to 100", "offset", "limit")
+	}
+
+	ascending := params.Order != nil && *params.Order == generated.Asc
+	if params.Order != nil && !ascending && *params.Order != generated.Desc {
+		return invalid("Unsupported order", "order")
+	}
+
+	// Fetch one estate past the page to know if there is a next one
+	estates, err := s.Repository.ListEstates(ctx.Request().Context(), offset, limit+1, ascending)
+	if err != nil {
+		return serverError(ctx, err, "Failed to list estates")
+	}
+
+	response := map[string]interface{}{}
+	if len(estates) > limit {
+		estates = estates[:limit]
+		response["next_offset"] = offset + limit
+	}
+	if estates == nil {
+		estates = []repository.Estate{}
+	}
+	response["estates"] = estates
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// 11. Handler for GET `/estate/:id` endpoint
+func (s *Server) GetEstateId(ctx echo.Context, uuid uuid.UUID) error {
+	estateId := ctx.Param("id")
+
+	estate, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	if estate.TreeCount, err = s.Repository.CountTreesByEstateId(ctx.Request().Context(), estateId); err != nil {
+		return serverError(ctx, err, "Failed to retrieve estate")
+	}
+
+	return ctx.JSON(http.StatusOK, estate)
+}
+
+// 12. Handler for PATCH `/estate/:id` endpoint
+func (s *Server) PatchEstateId(ctx echo.Context, uuid uuid.UUID) error {
+	estateId := ctx.Param("id")
+	var request struct {
+		Width     *int     `json:"width"`
+		Length    *int     `json:"length"`
+		Latitude  *float64 `json:"latitude"`
+		Longitude *float64 `json:"longitude"`
+		Bearing   *float64 `json:"bearing"`
+	}
+
+	if err := ctx.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+
+	if (request.Width != nil && *request.Width <= 0) || (request.Length != nil && *request.Length <= 0) {
+		return invalid("Width and Length must be greater than 0", "width", "length")
+	}
+
+	estate, err := s.estate(ctx, estateId)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Estate not found"})
+		return err
+	}
+
+	// Fields left out are kept
+	if request.Width != nil {
+		estate.Width = *request.Width
+	}
+	if request.Length != nil {
+		estate.Length = *request.Length
+	}
+	if estate.GeoReference, err = geoReference(estate.GeoReference, request.Latitude, request.Longitude, request.Bearing); err != nil {
+		return err
+	}
+
+	if err := s.Repository.UpdateEstate(ctx.Request().Context(), estate); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTreesOutOfBounds):
+			return newProblem(http.StatusConflict, codeTreesOutOfBounds, "Estate cannot be shrunk past its trees")
+		case errors.Is(err, repository.ErrZonesOutOfBounds):
+			return newProblem(http.StatusConflict, codeZonesOutOfBounds, "Estate cannot be shrunk past its zones")
+		case errors.Is(err, repository.ErrEstateNotFound):
+			return newProblem(http.StatusNotFound, codeEstateNotFound, "Estate not found")
+		}
+		return serverError(ctx, err, "Failed to update estate")
+	}
+
+	if estate.TreeCount, err = s.Repository.CountTreesByEstateId(ctx.Request().Context(), estateId); err != nil {
+		return serverError(ctx, err, "Failed to retrieve estate")
+	}
+
+	return ctx.JSON(http.StatusOK, estate)
+}
+
+// 13. Handler for DELETE `/estate/:id` endpoint
+func (s *Server) DeleteEstateId(ctx echo.Context, uuid uuid.UUID) error {
+	estateId := ctx.Param("id")
+
+	if err := s.Repository.DeleteEstate(ctx.Request().Context(), estateId); err != nil {
+		if errors.Is(err, repository.ErrEstateNotFound) {
+			return newProblem(http.StatusNotFound, codeEstateNotFound, "Estate not found")
 		}
+		return serverError(ctx, err, "Failed to delete estate")
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// 14. Handler for GET `/estate/:id/trees` endpoint
+func (s *Server) GetEstateIdTrees(ctx echo.Context, uuid uuid.UUID, params generated.GetEstateIdTreesParams) error {
+	estateId := ctx.Param("id")
+
+	offset, limit, ok := treePage(params.Offset, params.Limit)
+	if !ok {
+		return invalid("offset must be 0 or greater and limit within 1 to 1000", "offset", "limit")
+	}
+
+	filter := repository.TreeFilter{MinHeight: params.MinHeight, MaxHeight: params.MaxHeight}
+	if filter.MinHeight != nil && filter.MaxHeight != nil && *filter.MinHeight > *filter.MaxHeight {
+		return invalid("min_height cannot be greater than max_height", "min_height", "max_height")
+	}
+
+	bounds, ok := boundsParams(params.X1, params.Y1, params.X2, params.Y2)
+	if !ok {
+		return invalid("x1, y1, x2 and y2 must be set together, from 1 and with x1 <= x2 and y1 <= y2", "x1", "y1", "x2", "y2")
+	}
+	filter.Bounds = bounds
+
+	_, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	return s.respondTreePage(ctx, estateId, filter, offset, limit)
+}
+
+// treePage returns the offset and limit of a page of trees, or false when
+// they are out of range.
+func treePage(offsetParam, limitParam *int) (offset, limit int, ok bool) {
+	offset, limit = 0, defaultTreeLimit
+	if offsetParam != nil {
+		offset = *offsetParam
+	}
+	if limitParam != nil {
+		limit = *limitParam
 	}
+	return offset, limit, offset >= 0 && limit > 0 && limit <= maxTreeLimit
+}
+
+// respondTreePage responds with a page of the trees of an estate, along with
+// the offset of the next page if any.
+func (s *Server) respondTreePage(ctx echo.Context, estateId string, filter repository.TreeFilter, offset, limit int) error {
+	// Fetch one tree past the page to know if there is a next one
+	trees, err := s.Repository.ListTrees(ctx.Request().Context(), estateId, filter, offset, limit+1)
+	if err != nil {
+		return serverError(ctx, err, "Failed to list trees")
+	}
+
+	response := map[string]interface{}{}
+	if len(trees) > limit {
+		trees = trees[:limit]
+		response["next_offset"] = offset + limit
+	}
+	if trees == nil {
+		trees = []repository.Tree{}
+	}
+	response["trees"] = trees
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// 15. Handler for GET `/estate/:id/tree/:treeId` endpoint
+func (s *Server) GetEstateIdTreeTreeId(ctx echo.Context, uuid uuid.UUID, treeUuid uuid.UUID) error {
+	estateId, treeId := ctx.Param("id"), ctx.Param("treeId")
 
-	distance, err := s.Repository.GetDronePlanByEstateId(estateId)
+	tree, err := s.Repository.GetTreeById(ctx.Request().Context(), estateId, treeId)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Drone plan not found"})
+		if errors.Is(err, repository.ErrTreeNotFound) {
+			return newProblem(http.StatusNotFound, codeTreeNotFound, "Tree not found")
 		}
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve drone plans"})
+		return serverError(ctx, err, "Failed to retrieve tree")
+	}
+
+	return ctx.JSON(http.StatusOK, tree)
+}
+
+// 16. Handler for PATCH `/estate/:id/tree/:treeId` endpoint
+func (s *Server) PatchEstateIdTreeTreeId(ctx echo.Context, uuid uuid.UUID, treeUuid uuid.UUID) error {
+	estateId, treeId := ctx.Param("id"), ctx.Param("treeId")
+	var request struct {
+		X      *int `json:"x"`
+		Y      *int `json:"y"`
+		Height *int `json:"height"`
 	}
 
-	return ctx.JSON(http.StatusOK, distance)
+	if err := ctx.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+
+	if request.Height != nil && !validHeight(*request.Height) {
+		return invalid("Height must be within 1 to 30 meters", "height")
+	}
+
+	estate, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	tree, err := s.Repository.GetTreeById(ctx.Request().Context(), estateId, treeId)
+	if err != nil {
+		if errors.Is(err, repository.ErrTreeNotFound) {
+			return newProblem(http.StatusNotFound, codeTreeNotFound, "Tree not found")
+		}
+		return serverError(ctx, err, "Failed to retrieve tree")
+	}
+
+	// Fields left out are kept
+	if request.X != nil {
+		tree.X = *request.X
+	}
+	if request.Y != nil {
+		tree.Y = *request.Y
+	}
+	if request.Height != nil {
+		tree.Height = *request.Height
+	}
+
+	// Coordinates out of bounds from estate's plot
+	if !inEstate(estate, tree.X, tree.Y) {
+		return newProblem(http.StatusBadRequest, codeOutOfBounds, "Coordinates out of bounds")
+	}
+
+	if tree, err = s.Repository.UpdateTree(ctx.Request().Context(), estateId, tree); err != nil {
+		switch {
+		// Another tree stands on the new plot (handling racing condition)
+		case errors.Is(err, repository.ErrPlotOccupied):
+			return newProblem(http.StatusBadRequest, codePlotOccupied, "Plot already has a tree")
+		case errors.Is(err, repository.ErrPlotOutOfBounds):
+			return newProblem(http.StatusBadRequest, codeOutOfBounds, "Coordinates out of bounds")
+		case errors.Is(err, repository.ErrTreeNotFound):
+			return newProblem(http.StatusNotFound, codeTreeNotFound, "Tree not found")
+		case errors.Is(err, repository.ErrEstateNotFound):
+			return newProblem(http.StatusNotFound, codeEstateNotFound, "Estate not found")
+		}
+		return serverError(ctx, err, "Failed to update tree")
+	}
+
+	return ctx.JSON(http.StatusOK, tree)
+}
+
+// 17. Handler for DELETE `/estate/:id/tree/:treeId` endpoint
+func (s *Server) DeleteEstateIdTreeTreeId(ctx echo.Context, uuid uuid.UUID, treeUuid uuid.UUID) error {
+	estateId, treeId := ctx.Param("id"), ctx.Param("treeId")
+
+	if err := s.Repository.DeleteTree(ctx.Request().Context(), estateId, treeId); err != nil {
+		if errors.Is(err, repository.ErrTreeNotFound) {
+			return newProblem(http.StatusNotFound, codeTreeNotFound, "Tree not found")
+		}
+		return serverError(ctx, err, "Failed to delete tree")
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// 18. Handler for POST `/estate/:id/trees/bulk` endpoint
+func (s *Server) PostEstateIdTreesBulk(ctx echo.Context, uuid uuid.UUID, params generated.PostEstateIdTreesBulkParams) error {
+	estateId := ctx.Param("id")
+
+	atomic := params.Mode == nil || *params.Mode == generated.Atomic
+	if !atomic && *params.Mode != generated.BestEffort {
+		return invalid("Unsupported mode", "mode")
+	}
+
+	rows, err := readBulkTrees(ctx)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return newProblem(http.StatusRequestEntityTooLarge, codeBodyTooLarge, "Bulk imports are limited to 4 MiB")
+	case errors.Is(err, errTooManyTrees):
+		return invalid("1 to 10000 trees can be imported at once")
+	case err != nil:
+		return invalidBody(err)
+	case len(rows) == 0:
+		return invalid("1 to 10000 trees can be imported at once")
+	}
+
+	estate, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	// Every row is checked like a single tree, and only the valid ones are
+	// imported. positions maps every imported tree back to its row.
+	report := make([]bulkTreeReport, len(rows))
+	trees := make([]repository.Tree, 0, len(rows))
+	positions := make([]int, 0, len(rows))
+	plots := make(map[planner.Plot]bool, len(rows))
+	for i, row := range rows {
+		plot := planner.Plot{X: row.X, Y: row.Y}
+		report[i].Row = i + 1
+
+		switch {
+		case row.invalid:
+			report[i].Error = bulkInvalidRow
+		case !validHeight(row.Height):
+			report[i].Error = bulkInvalidHeight
+		case !inEstate(estate, row.X, row.Y):
+			report[i].Error = bulkOutOfBounds
+		case plots[plot]:
+			report[i].Error = bulkDuplicatePlot
+		default:
+			plots[plot] = true
+			trees = append(trees, repository.Tree{X: row.X, Y: row.Y, Height: row.Height})
+			positions = append(positions, i)
+		}
+	}
+
+	if atomic && len(trees) < len(rows) {
+		return importRejected(report)
+	}
+
+	imported, err := s.Repository.ImportTrees(ctx.Request().Context(), estateId, trees, atomic)
+	if err != nil {
+		// The estate was shrunk in the meantime
+		if errors.Is(err, repository.ErrPlotOutOfBounds) {
+			return newProblem(http.StatusBadRequest, codeOutOfBounds, "Coordinates out of bounds")
+		}
+		return serverError(ctx, err, "Failed to import trees")
+	}
+
+	rejected := false
+	for i, tree := range imported {
+		if tree.Occupied {
+			report[positions[i]].Error = bulkDuplicatePlot
+			rejected = true
+		}
+		report[positions[i]].Id = tree.Id
+	}
+
+	// Nothing was imported when a plot already had a tree
+	if atomic && rejected {
+		return importRejected(report)
+	}
+
+	return ctx.JSON(http.StatusCreated, bulkTreesResponse(report))
+}
+
+// Errors reported on the rows of a bulk import.
+const (
+	bulkInvalidRow    = "invalid_row"
+	bulkInvalidHeight = "invalid_height"
+	bulkOutOfBounds   = "out_of_bounds"
+	bulkDuplicatePlot = "duplicate_plot"
+)
+
+// bulkTree is a row of a bulk import. invalid is set on CSV rows that are not
+// made of three integers.
+type bulkTree struct {
+	X       int `json:"x"`
+	Y       int `json:"y"`
+	Height  int `json:"height"`
+	invalid bool
+}
+
+// bulkTreesReport sums up the outcome of a bulk import.
+type bulkTreesReport struct {
+	Accepted int              `json:"accepted"`
+	Rejected int              `json:"rejected"`
+	Rows     []bulkTreeReport `json:"rows"`
+}
+
+// bulkTreeReport is the outcome of a row of a bulk import, numbered from 1.
+// Rows of a cancelled import have neither id nor error.
+type bulkTreeReport struct {
+	Row   int    `json:"row"`
+	Id    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func bulkTreesResponse(report []bulkTreeReport) *bulkTreesReport {
+	accepted, rejected := 0, 0
+	for _, row := range report {
+		if row.Id != "" {
+			accepted++
+		}
+		if row.Error != "" {
+			rejected++
+		}
+	}
+
+	return &bulkTreesReport{Accepted: accepted, Rejected: rejected, Rows: report}
+}
+
+// importRejected is the problem of an atomic import cancelled on a rejected
+// row, which carries the report of every row.
+func importRejected(report []bulkTreeReport) *Problem {
+	problem := newProblem(http.StatusBadRequest, codeImportRejected, "No tree imported as some rows were rejected")
+	problem.Report = bulkTreesResponse(report)
+	return problem
+}
+
+// errTooManyTrees is returned by readBulkTrees past maxBulkTrees rows.
+var errTooManyTrees = errors.New("too many trees to import at once")
+
+// readBulkTrees reads the rows of a bulk import from a JSON array, or from a
+// CSV body of x,y,height records with an optional header. It stops reading
+// past maxBulkTrees rows or maxBulkBytes, returning errTooManyTrees or an
+// *http.MaxBytesError.
+func readBulkTrees(ctx echo.Context) (rows []bulkTree, err error) {
+	body := http.MaxBytesReader(ctx.Response().Writer, ctx.Request().Body, maxBulkBytes)
+
+	if !strings.HasPrefix(ctx.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		decoder := json.NewDecoder(body)
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return nil, errors.Join(errors.New("trees must be a JSON array"), err)
+		}
+		for decoder.More() {
+			if len(rows) == maxBulkTrees {
+				return nil, errTooManyTrees
+			}
+
+			var row bulkTree
+			if err = decoder.Decode(&row); err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+		_, err = decoder.Token()
+		return rows, err
+	}
+
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if first && len(record) == 3 && strings.EqualFold(record[0], "x") {
+			continue
+		}
+		if len(rows) == maxBulkTrees {
+			return nil, errTooManyTrees
+		}
+		rows = append(rows, parseBulkRecord(record))
+	}
+}
+
+func parseBulkRecord(record []string) (row bulkTree) {
+	if len(record) != 3 {
+		return bulkTree{invalid: true}
+	}
+
+	var errs [3]error
+	row.X, errs[0] = strconv.Atoi(strings.TrimSpace(record[0]))
+	row.Y, errs[1] = strconv.Atoi(strings.TrimSpace(record[1]))
+	row.Height, errs[2] = strconv.Atoi(strings.TrimSpace(record[2]))
+	row.invalid = errs[0] != nil || errs[1] != nil || errs[2] != nil
+	return row
+}
+
+// 19. Handler for GET `/estate/:id/trees/export` endpoint
+func (s *Server) GetEstateIdTreesExport(ctx echo.Context, uuid uuid.UUID) error {
+	estateId := ctx.Param("id")
+
+	format, ok := export.Negotiate(ctx.Request().Header.Get(echo.HeaderAccept))
+	if !ok {
+		return newProblem(http.StatusNotAcceptable, codeNotAcceptable, "Trees can be exported as text/csv, application/x-ndjson or application/vnd.apache.parquet")
+	}
+
+	_, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	contentType, extension, _ := export.ContentType(format)
+	stream(ctx)
+	ctx.Response().Header().Set(echo.HeaderContentType, contentType)
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="trees-%s.%s"`, estateId, extension))
+	ctx.Response().WriteHeader(http.StatusOK)
+
+	return export.Export(ctx.Response(), format, func(visit func(tree repository.Tree) error) error {
+		return s.Repository.StreamTrees(ctx.Request().Context(), estateId, visit)
+	})
+}
+
+// 20. Handler for POST `/estate/:id/tree/:treeId/measurements` endpoint
+func (s *Server) PostEstateIdTreeTreeIdMeasurements(ctx echo.Context, uuid uuid.UUID, treeUuid uuid.UUID) error {
+	estateId, treeId := ctx.Param("id"), ctx.Param("treeId")
+	var request struct {
+		Height     int        `json:"height"`
+		MeasuredAt *time.Time `json:"measured_at"`
+		Source     string     `json:"source"`
+	}
+
+	if err := ctx.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+
+	if !validHeight(request.Height) {
+		return invalid("Height must be within 1 to 30 meters", "height")
+	}
+
+	// Measurements are taken now unless told otherwise, never in the future
+	measurement := repository.Measurement{Height: request.Height, MeasuredAt: time.Now(), Source: request.Source}
+	if request.MeasuredAt != nil {
+		if request.MeasuredAt.After(measurement.MeasuredAt) {
+			return invalid("measured_at cannot be in the future", "measured_at")
+		}
+		measurement.MeasuredAt = *request.MeasuredAt
+	}
+
+	if measurement.Source == "" {
+		measurement.Source = defaultMeasurementSource
+	}
+	if len(measurement.Source) > maxMeasurementSource {
+		return invalid("Source must be at most 50 characters", "source")
+	}
+
+	recorded, err := s.Repository.RecordMeasurement(ctx.Request().Context(), estateId, treeId, measurement)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTreeNotFound):
+			return newProblem(http.StatusNotFound, codeTreeNotFound, "Tree not found")
+		case errors.Is(err, repository.ErrMeasuredBeforePlanting):
+			return invalid("measured_at cannot be before the tree was planted", "measured_at")
+		}
+		return serverError(ctx, err, "Failed to record measurement")
+	}
+
+	return ctx.JSON(http.StatusCreated, recorded)
+}
+
+// 21. Handler for GET `/estate/:id/tree/:treeId/history` endpoint
+func (s *Server) GetEstateIdTreeTreeIdHistory(ctx echo.Context, uuid uuid.UUID, treeUuid uuid.UUID) error {
+	estateId, treeId := ctx.Param("id"), ctx.Param("treeId")
+
+	// Check the tree exist or not, since a tree always has a history
+	_, err := s.Repository.GetTreeById(ctx.Request().Context(), estateId, treeId)
+	if err != nil {
+		if errors.Is(err, repository.ErrTreeNotFound) {
+			return newProblem(http.StatusNotFound, codeTreeNotFound, "Tree not found")
+		}
+		return serverError(ctx, err, "Failed to retrieve tree")
+	}
+
+	measurements, err := s.Repository.GetMeasurementsByTreeId(ctx.Request().Context(), estateId, treeId)
+	if err != nil {
+		return serverError(ctx, err, "Failed to retrieve tree history")
+	}
+	if measurements == nil {
+		measurements = []repository.Measurement{}
+	}
+
+	response := map[string]interface{}{"measurements": measurements}
+	if rate, ok := growthRate(measurements); ok {
+		response["growth_rate"] = rate
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// growthRate returns how fast a tree grew from its first to its latest
+// measurement, in meters per year, and false when they were taken at the same
+// time. measurements are ordered oldest first.
+func growthRate(measurements []repository.Measurement) (rate float64, ok bool) {
+	if len(measurements) < 2 {
+		return 0, false
+	}
+
+	first, latest := measurements[0], measurements[len(measurements)-1]
+	years := latest.MeasuredAt.Sub(first.MeasuredAt).Hours() / (24 * 365.25)
+	if years <= 0 {
+		return 0, false
+	}
+	return float64(latest.Height-first.Height) / years, true
+}
+
+// 22. Handler for POST `/estate/:id/zone` endpoint
+func (s *Server) PostEstateIdZone(ctx echo.Context, uuid uuid.UUID) error {
+	estateId := ctx.Param("id")
+	var request struct {
+		Name string `json:"name"`
+		X1   *int   `json:"x1"`
+		Y1   *int   `json:"y1"`
+		X2   *int   `json:"x2"`
+		Y2   *int   `json:"y2"`
+	}
+
+	if err := ctx.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+
+	zone := repository.Zone{Name: strings.TrimSpace(request.Name)}
+	if zone.Name == "" || len(zone.Name) > maxZoneName {
+		return invalid("Name must be 1 to 100 characters", "name")
+	}
+
+	bounds, ok := boundsParams(request.X1, request.Y1, request.X2, request.Y2)
+	if !ok || bounds == nil {
+		return invalid("x1, y1, x2 and y2 are required, from 1 and with x1 <= x2 and y1 <= y2", "x1", "y1", "x2", "y2")
+	}
+	zone.Bounds = *bounds
+
+	estate, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	if !boundsInEstate(estate, zone.Bounds) {
+		return newProblem(http.StatusBadRequest, codeOutOfBounds, "Coordinates out of bounds")
+	}
+
+	id, err := s.Repository.CreateZone(ctx.Request().Context(), estateId, zone)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrZoneOverlaps):
+			return newProblem(http.StatusConflict, codeZoneOverlaps, "Zone overlaps another zone")
+		// The estate was shrunk in the meantime
+		case errors.Is(err, repository.ErrPlotOutOfBounds):
+			return newProblem(http.StatusBadRequest, codeOutOfBounds, "Coordinates out of bounds")
+		case errors.Is(err, repository.ErrEstateNotFound):
+			return newProblem(http.StatusNotFound, codeEstateNotFound, "Estate not found")
+		case errors.Is(err, repository.ErrZoneNameTaken):
+			return newProblem(http.StatusConflict, codeZoneNameTaken, "Zone name already taken")
+		}
+		return serverError(ctx, err, "Failed to create zone")
+	}
+
+	return ctx.JSON(http.StatusCreated, map[string]string{"id": id})
+}
+
+// 23. Handler for GET `/estate/:id/zones` endpoint
+func (s *Server) GetEstateIdZones(ctx echo.Context, uuid uuid.UUID) error {
+	estateId := ctx.Param("id")
+
+	_, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	zones, err := s.Repository.ListZones(ctx.Request().Context(), estateId)
+	if err != nil {
+		return serverError(ctx, err, "Failed to list zones")
+	}
+	if zones == nil {
+		zones = []repository.Zone{}
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{"zones": zones})
+}
+
+// 24. Handler for GET `/estate/:id/zone/:zoneId` endpoint
+func (s *Server) GetEstateIdZoneZoneId(ctx echo.Context, uuid uuid.UUID, zoneUuid uuid.UUID) error {
+	zone, err := s.Repository.GetZoneById(ctx.Request().Context(), ctx.Param("id"), ctx.Param("zoneId"))
+	if err != nil {
+		if errors.Is(err, repository.ErrZoneNotFound) {
+			return newProblem(http.StatusNotFound, codeZoneNotFound, "Zone not found")
+		}
+		return serverError(ctx, err, "Failed to retrieve zone")
+	}
+
+	return ctx.JSON(http.StatusOK, zone)
+}
+
+// 25. Handler for DELETE `/estate/:id/zone/:zoneId` endpoint
+func (s *Server) DeleteEstateIdZoneZoneId(ctx echo.Context, uuid uuid.UUID, zoneUuid uuid.UUID) error {
+	if err := s.Repository.DeleteZone(ctx.Request().Context(), ctx.Param("id"), ctx.Param("zoneId")); err != nil {
+		if errors.Is(err, repository.ErrZoneNotFound) {
+			return newProblem(http.StatusNotFound, codeZoneNotFound, "Zone not found")
+		}
+		return serverError(ctx, err, "Failed to delete zone")
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// 26. Handler for GET `/estate/:id/zone/:zoneId/stats` endpoint
+func (s *Server) GetEstateIdZoneZoneIdStats(ctx echo.Context, uuid uuid.UUID, zoneUuid uuid.UUID, params generated.GetEstateIdZoneZoneIdStatsParams) error {
+	estateId := ctx.Param("id")
+
+	options, err := statsOptions(params.Include, params.Percentiles, params.BucketWidth)
+	if err != nil {
+		return invalid(err.Error())
+	}
+
+	zone, err := s.Repository.GetZoneById(ctx.Request().Context(), estateId, ctx.Param("zoneId"))
+	if err != nil {
+		if errors.Is(err, repository.ErrZoneNotFound) {
+			return newProblem(http.StatusNotFound, codeZoneNotFound, "Zone not found")
+		}
+		return serverError(ctx, err, "Failed to retrieve zone")
+	}
+	options.Bounds = &zone.Bounds
+
+	return s.respondStats(ctx, estateId, params.AsOf, options)
+}
+
+// 27. Handler for GET `/estate/:id/zone/:zoneId/trees` endpoint
+func (s *Server) GetEstateIdZoneZoneIdTrees(ctx echo.Context, uuid uuid.UUID, zoneUuid uuid.UUID, params generated.GetEstateIdZoneZoneIdTreesParams) error {
+	estateId := ctx.Param("id")
+
+	offset, limit, ok := treePage(params.Offset, params.Limit)
+	if !ok {
+		return invalid("offset must be 0 or greater and limit within 1 to 1000", "offset", "limit")
+	}
+
+	filter := repository.TreeFilter{MinHeight: params.MinHeight, MaxHeight: params.MaxHeight}
+	if filter.MinHeight != nil && filter.MaxHeight != nil && *filter.MinHeight > *filter.MaxHeight {
+		return invalid("min_height cannot be greater than max_height", "min_height", "max_height")
+	}
+
+	zone, err := s.Repository.GetZoneById(ctx.Request().Context(), estateId, ctx.Param("zoneId"))
+	if err != nil {
+		if errors.Is(err, repository.ErrZoneNotFound) {
+			return newProblem(http.StatusNotFound, codeZoneNotFound, "Zone not found")
+		}
+		return serverError(ctx, err, "Failed to retrieve zone")
+	}
+	filter.Bounds = &zone.Bounds
+
+	return s.respondTreePage(ctx, estateId, filter, offset, limit)
+}
+
+// 28. Handler for GET `/estate/:id/zone/:zoneId/drone-plan` endpoint
+func (s *Server) GetEstateIdZoneZoneIdDronePlan(ctx echo.Context, uuid uuid.UUID, zoneUuid uuid.UUID, params generated.GetEstateIdZoneZoneIdDronePlanParams) error {
+	estateId := ctx.Param("id")
+
+	if params.MaxDistance != nil && *params.MaxDistance <= 0 {
+		return invalid("max_distance must be greater than 0", "max_distance")
+	}
+
+	estate, err := s.estate(ctx, estateId)
+	if err != nil {
+		return err
+	}
+
+	zone, err := s.Repository.GetZoneById(ctx.Request().Context(), estateId, ctx.Param("zoneId"))
+	if err != nil {
+		if errors.Is(err, repository.ErrZoneNotFound) {
+			return newProblem(http.StatusNotFound, codeZoneNotFound, "Zone not found")
+		}
+		return serverError(ctx, err, "Failed to retrieve zone")
+	}
+
+	opts, err := plannerOptions(ctx.Request().Context(), s.Repository, estateId, estate)
+	if err != nil {
+		return serverError(ctx, err, "Failed to retrieve drone plans")
+	}
+	p := planner.NewPlanner(zonePlannerOptions(opts, zone.Bounds))
+
+	if params.MaxDistance != nil {
+		distance, rest, err := p.DistanceWithLimit(ctx.Request().Context(), *params.MaxDistance)
+		if err != nil {
+			return serverError(ctx, err, "Failed to plan drone flight")
+		}
+		return ctx.JSON(http.StatusOK, repository.DronePlan{
+			Distance: distance,
+			Rest:     &repository.RestPoint{X: rest.X + zone.X1 - 1, Y: rest.Y + zone.Y1 - 1},
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, repository.DronePlan{Distance: p.Distance()})
+}
+
+// zonePlannerOptions narrows the planner options of an estate down to a zone,
+// flown as if it were an estate of its own: the trees outside are left out
+// and the plots are numbered from the corner of the zone.
+func zonePlannerOptions(opts planner.NewPlannerOptions, bounds repository.Bounds) planner.NewPlannerOptions {
+	trees := make([]planner.Tree, 0, len(opts.Trees))
+	for _, tree := range opts.Trees {
+		if tree.X >= bounds.X1 && tree.X <= bounds.X2 && tree.Y >= bounds.Y1 && tree.Y <= bounds.Y2 {
+			trees = append(trees, planner.Tree{X: tree.X - bounds.X1 + 1, Y: tree.Y - bounds.Y1 + 1, Height: tree.Height})
+		}
+	}
+
+	opts.Width, opts.Length = bounds.X2-bounds.X1+1, bounds.Y2-bounds.Y1+1
+	opts.Trees = trees
+	return opts
+}
+
+// 29. Handler for GET `/stats` endpoint
+func (s *Server) GetStats(ctx echo.Context, params generated.GetStatsParams) error {
+	var filter repository.PortfolioFilter
+	if params.EstateIds != nil {
+		if len(*params.EstateIds) == 0 || len(*params.EstateIds) > maxPortfolioEstates {
+			return invalid("estate_ids must list 1 to 100 estates", "estate_ids")
+		}
+		filter.EstateIds = make([]string, len(*params.EstateIds))
+		for i, id := range *params.EstateIds {
+			filter.EstateIds[i] = id.String()
+		}
+	}
+
+	filter.CreatedFrom, filter.CreatedTo = params.CreatedFrom, params.CreatedTo
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && filter.CreatedFrom.After(*filter.CreatedTo) {
+		return invalid("created_from cannot be after created_to", "created_from", "created_to")
+	}
+
+	top := defaultPortfolioTop
+	if params.Top != nil {
+		top = *params.Top
+	}
+	if top < 1 || top > maxPortfolioTop {
+		return invalid("top must be within 1 to 20", "top")
+	}
+
+	bucketWidth := defaultBucketWidth
+	if params.BucketWidth != nil {
+		bucketWidth = *params.BucketWidth
+	}
+	if bucketWidth < 1 || bucketWidth > maxTreeHeight {
+		return invalid("bucket_width must be within 1 to 30 meters", "bucket_width")
+	}
+
+	stats, err := s.Repository.GetPortfolioStats(ctx.Request().Context(), filter, top, bucketWidth)
+	if err != nil {
+		return serverError(ctx, err, "Failed to retrieve stats")
+	}
+
+	return ctx.JSON(http.StatusOK, stats)
+}
+
+// statsOptions returns the stats asked for through the include parameter.
+// Setting percentiles or bucket_width includes them as well.
+func statsOptions(includes *[]generated.StatsInclude, percentiles *[]float64, bucketWidth *int) (options repository.StatsOptions, err error) {
+	if includes != nil {
+		for _, include := range *includes {
+			switch include {
+			case generated.Mean:
+				options.Mean = true
+			case generated.Stddev:
+				options.StdDev = true
+			case generated.Percentiles:
+				options.Percentiles = []float64{10, 90}
+			case generated.Histogram:
+				options.BucketWidth = defaultBucketWidth
+			case generated.Density:
+				options.Density = true
+			default:
+				return options, errors.New("include must be a list of mean, stddev, percentiles, histogram and density")
+			}
+		}
+	}
+
+	if percentiles != nil {
+		if len(*percentiles) == 0 || len(*percentiles) > maxStatsPercentiles {
+			return options, errors.New("percentiles must list 1 to 20 percentages")
+		}
+		for _, percentile := range *percentiles {
+			if percentile < 0 || percentile > 100 {
+				return options, errors.New("percentiles must be within 0 to 100")
+			}
+		}
+		options.Percentiles = *percentiles
+	}
+
+	if bucketWidth != nil {
+		if *bucketWidth < 1 || *bucketWidth > maxTreeHeight {
+			return options, errors.New("bucket_width must be within 1 to 30 meters")
+		}
+		options.BucketWidth = *bucketWidth
+	}
+
+	return options, nil
+}
+
+// parseAsOf parses the as_of parameter, either a date-time or a date that
+// stands for the end of that day in UTC.
+func parseAsOf(value string) (asOf time.Time, ok bool) {
+	if asOf, err := time.Parse(time.RFC3339, value); err == nil {
+		return asOf, true
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Microsecond), true
+}
+
+// geoReference applies the latitude, longitude and bearing of a request to the
+// geo-reference of an estate, nil when it has none, or returns the problem to
+// respond with when they are invalid. An estate without geo-reference needs
+// both latitude and longitude to get one, the bearing defaulting to
+// defaultBearing.
+func geoReference(geo *repository.GeoReference, latitude, longitude, bearing *float64) (*repository.GeoReference, error) {
+	if latitude == nil && longitude == nil && bearing == nil {
+		return geo, nil
+	}
+
+	if geo == nil {
+		if latitude == nil || longitude == nil {
+			return nil, invalid("Latitude and Longitude must be set together", "latitude", "longitude")
+		}
+		geo = &repository.GeoReference{Bearing: defaultBearing}
+	}
+
+	updated := *geo
+	if latitude != nil {
+		updated.Latitude = *latitude
+	}
+	if longitude != nil {
+		updated.Longitude = *longitude
+	}
+	if bearing != nil {
+		updated.Bearing = *bearing
+	}
+
+	if updated.Latitude < -90 || updated.Latitude > 90 || updated.Longitude < -180 || updated.Longitude > 180 || updated.Bearing < 0 || updated.Bearing >= 360 {
+		return nil, invalid("Latitude, Longitude or Bearing out of range", "latitude", "longitude", "bearing")
+	}
+	return &updated, nil
+}
+
+// estate returns the estate of a request, or the problem to respond with
+// when it cannot be retrieved.
+func (s *Server) estate(ctx echo.Context, estateId string) (repository.Estate, error) {
+	estate, err := s.Repository.GetEstateById(ctx.Request().Context(), estateId)
+	if err != nil {
+		if errors.Is(err, repository.ErrEstateNotFound) {
+			return estate, newProblem(http.StatusNotFound, codeEstateNotFound, "Estate not found")
+		}
+		return estate, serverError(ctx, err, "Failed to retrieve estate")
+	}
+	return estate, nil
+}
+
+// validHeight tells whether a tree height is within 1 to 30 meters.
+func validHeight(height int) bool {
+	return height >= 1 && height <= maxTreeHeight
+}
+
+// inEstate tells whether plot (x, y) lies within an estate.
+func inEstate(estate repository.Estate, x, y int) bool {
+	return x >= 1 && y >= 1 && x <= estate.Width && y <= estate.Length
+}
+
+// boundsInEstate tells whether every plot of bounds lies within an estate.
+func boundsInEstate(estate repository.Estate, bounds repository.Bounds) bool {
+	return inEstate(estate, bounds.X1, bounds.Y1) && inEstate(estate, bounds.X2, bounds.Y2)
+}
+
+// boundsParams returns the rectangle of plots set through the x1, y1, x2 and
+// y2 parameters, or nil when none is set. ok is false when only some are set
+// or when they do not make a rectangle.
+func boundsParams(x1, y1, x2, y2 *int) (bounds *repository.Bounds, ok bool) {
+	if x1 == nil && y1 == nil && x2 == nil && y2 == nil {
+		return nil, true
+	}
+	if x1 == nil || y1 == nil || x2 == nil || y2 == nil {
+		return nil, false
+	}
+
+	bounds = &repository.Bounds{X1: *x1, Y1: *y1, X2: *x2, Y2: *y2}
+	return bounds, bounds.X1 >= 1 && bounds.Y1 >= 1 && bounds.X1 <= bounds.X2 && bounds.Y1 <= bounds.Y2
+}
+
+// newPlanner loads the trees and the drone config of an estate from repo to
+// plan the route over it. A nil traversal flies the default pattern.
+func newPlanner(ctx context.Context, repo repository.RepositoryInterface, estateId string, estate repository.Estate, traversal planner.Traversal) (*planner.Planner, error) {
+	opts, err := plannerOptions(ctx, repo, estateId, estate)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Traversal = traversal
+	return planner.NewPlanner(opts), nil
+}
+
+func plannerOptions(ctx context.Context, repo repository.RepositoryInterface, estateId string, estate repository.Estate) (opts planner.NewPlannerOptions, err error) {
+	trees, err := repo.GetTreesByEstateId(ctx, estateId)
+	if err != nil {
+		return opts, err
+	}
+
+	config, err := droneConfig(ctx, repo, estateId)
+	if err != nil {
+		return opts, err
+	}
+
+	return planner.NewPlannerOptions{
+		Width:  estate.Width,
+		Length: estate.Length,
+		Trees:  toPlannerTrees(trees),
+		Config: planner.Config{
+			PlotSize:        config.PlotSize,
+			Clearance:       config.Clearance,
+			TakeoffAltitude: config.TakeoffAltitude,
+		},
+	}, nil
+}
+
+// droneConfig returns the drone config of an estate, or the default one when
+// it was never configured.
+func droneConfig(ctx context.Context, repo repository.RepositoryInterface, estateId string) (repository.DroneConfig, error) {
+	config, err := repo.GetDroneConfigByEstateId(ctx, estateId)
+	if errors.Is(err, repository.ErrDroneConfigNotFound) {
+		return defaultDroneConfig(), nil
+	}
+	return config, err
+}
+
+func defaultDroneConfig() repository.DroneConfig {
+	defaults := planner.DefaultConfig()
+	return repository.DroneConfig{
+		PlotSize:        defaults.PlotSize,
+		Clearance:       defaults.Clearance,
+		TakeoffAltitude: defaults.TakeoffAltitude,
+	}
+}
+
+func toPlannerTrees(trees []repository.Tree) []planner.Tree {
+	result := make([]planner.Tree, 0, len(trees))
+	for _, tree := range trees {
+		result = append(result, planner.Tree{X: tree.X, Y: tree.Y, Height: tree.Height})
+	}
+	return result
 }
@@ -1,20 +1,36 @@
 package handler
 
 import (
-	"database/sql"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
-	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/unklejo/swpr.drone/generated"
+	"github.com/unklejo/swpr.drone/planner"
 	"github.com/unklejo/swpr.drone/repository"
 )
 
+// errDatabase stands for an error of the database the repository does not
+// translate
+var errDatabase = errors.New("database error")
+
+// serve responds to the error returned by a handler, like the server does.
+func serve(c echo.Context, err error) {
+	if err != nil {
+		ErrorHandler(err, c)
+	}
+}
+
 // 1. Create estate test files
 
 func TestCreateEstate_Success(t *testing.T) {
@@ -32,9 +48,9 @@ func TestCreateEstate_Success(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().CreateEstate(10, 10).Return("1", nil)
+	mockRepo.EXPECT().CreateEstate(gomock.Any(), 10, 10, nil).Return("1", nil)
 
-	h.PostEstate(c)
+	serve(c, h.PostEstate(c))
 
 	assert.Equal(t, http.StatusCreated, rec.Code)
 	assert.Contains(t, rec.Body.String(), "id")
@@ -55,7 +71,7 @@ func TestCreateEstate_InvalidInput(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	h.PostEstate(c)
+	serve(c, h.PostEstate(c))
 
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Invalid input")
@@ -76,7 +92,7 @@ func TestCreateEstate_NegativeValues(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	h.PostEstate(c)
+	serve(c, h.PostEstate(c))
 
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Width and Length must be greater than 0")
@@ -97,15 +113,69 @@ func TestCreateEstate_InternalServerError(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().CreateEstate(10, 10).Return("", repository.ErrDatabaseError)
+	mockRepo.EXPECT().CreateEstate(gomock.Any(), 10, 10, nil).Return("", errDatabase)
 
-	h.PostEstate(c)
+	serve(c, h.PostEstate(c))
 
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Failed to create estate")
 }
 
+func TestCreateEstate_WithGeoReference(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate", strings.NewReader(`{"width":10, "length":10, "latitude":-6.2, "longitude":106.8}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().CreateEstate(gomock.Any(), 10, 10, &repository.GeoReference{Latitude: -6.2, Longitude: 106.8, Bearing: 90}).Return("1", nil)
+
+	serve(c, h.PostEstate(c))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestCreateEstate_IncompleteGeoReference(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate", strings.NewReader(`{"width":10, "length":10, "latitude":-6.2}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	serve(c, h.PostEstate(c))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Latitude and Longitude must be set together")
+}
+
 // 2. Add tree test files
+
+// expectTx runs the unit of work of the handler on mockRepo, and records in
+// committed whether the transaction would have been committed.
+func expectTx(mockRepo *repository.MockRepositoryInterface, opts repository.TxOptions, committed *bool) {
+	mockRepo.EXPECT().WithTx(gomock.Any(), opts, gomock.Any()).DoAndReturn(func(ctx context.Context, opts repository.TxOptions, fn func(repo repository.RepositoryInterface) error) error {
+		err := fn(mockRepo)
+		*committed = err == nil
+		return err
+	})
+}
+
 func TestAddTree_Success(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(`{"x": 1, "y": 10, "height": 10}`))
@@ -123,10 +193,10 @@ func TestAddTree_Success(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
-	mockRepo.EXPECT().AddTree(gomock.Any(), 1, 10, 10).Return("1", nil)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().AddTree(gomock.Any(), gomock.Any(), 1, 10, 10).Return("1", nil)
 
-	h.PostEstateIdTree(c, uuid.Nil)
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
 
 	assert.Equal(t, http.StatusCreated, rec.Code)
 	assert.Contains(t, rec.Body.String(), "id")
@@ -149,12 +219,50 @@ func TestAddTree_InvalidInput(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	h.PostEstateIdTree(c, uuid.Nil)
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
 
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Invalid input")
 }
 
+// A single tree keeps the height check it always had, unlike the bulk import
+func TestAddTree_HeightCheck(t *testing.T) {
+	for _, tc := range []struct {
+		height int
+		valid  bool
+	}{{-1, false}, {0, true}, {29, true}, {30, false}} {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(fmt.Sprintf(`{"x": 1, "y": 1, "height": %d}`, tc.height)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		ctrl := gomock.NewController(t)
+
+		mockRepo := repository.NewMockRepositoryInterface(ctrl)
+		h := &Server{
+			Repository: mockRepo,
+		}
+
+		if tc.valid {
+			mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+			mockRepo.EXPECT().AddTree(gomock.Any(), "1", 1, 1, tc.height).Return("1", nil)
+		}
+
+		serve(c, h.PostEstateIdTree(c, uuid.Nil))
+
+		if tc.valid {
+			assert.Equal(t, http.StatusCreated, rec.Code, "height %d", tc.height)
+		} else {
+			assert.Equal(t, http.StatusBadRequest, rec.Code, "height %d", tc.height)
+			assert.Contains(t, rec.Body.String(), "Height must be within 1 to 30 meters")
+		}
+		ctrl.Finish()
+	}
+}
+
 func TestAddTree_EstateNotFound(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(`{"x": 1, "y": 1, "height": 10}`))
@@ -172,9 +280,9 @@ func TestAddTree_EstateNotFound(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "", Width: 0, Length: 0}, sql.ErrNoRows)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "", Width: 0, Length: 0}, repository.ErrEstateNotFound)
 
-	h.PostEstateIdTree(c, uuid.Nil)
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
 
 	assert.Equal(t, http.StatusNotFound, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Estate not found")
@@ -197,10 +305,10 @@ func TestAddTree_DatabaseError(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
-	mockRepo.EXPECT().AddTree(gomock.Any(), 1, 1, 10).Return("", repository.ErrDatabaseError)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().AddTree(gomock.Any(), gomock.Any(), 1, 1, 10).Return("", errDatabase)
 
-	h.PostEstateIdTree(c, uuid.Nil)
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
 
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Failed to add tree")
@@ -223,15 +331,41 @@ func TestAddTree_PlotAlreadyHasTree(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
-	mockRepo.EXPECT().AddTree(gomock.Any(), 1, 1, 10).Return("", &pq.Error{Code: "23505"})
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().AddTree(gomock.Any(), gomock.Any(), 1, 1, 10).Return("", repository.ErrPlotOccupied)
 
-	h.PostEstateIdTree(c, uuid.Nil)
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
 
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Plot already has a tree")
 }
 
+func TestAddTree_EstateShrunkMeanwhile(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(`{"x": 8, "y": 1, "height": 10}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().AddTree(gomock.Any(), gomock.Any(), 8, 1, 10).Return("", repository.ErrPlotOutOfBounds)
+
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Coordinates out of bounds")
+}
+
 func TestAddTree_CoordinatesOutOfBounds(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(`{"x": 0, "y": 12, "height": 10}`))
@@ -245,11 +379,11 @@ func TestAddTree_CoordinatesOutOfBounds(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepo := repository.NewMockRepositoryInterface(ctrl)
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
 
 	h := &Server{Repository: mockRepo}
 
-	h.PostEstateIdTree(c, uuid.Nil)
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
 
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Coordinates out of bounds")
@@ -273,10 +407,10 @@ func TestGetEstateStats_Success(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
-	mockRepo.EXPECT().GetEstateStatsById("1").Return(repository.EstateStats{Count: 3, MaxHeight: 20, MinHeight: 5, MedianHeight: 15}, nil)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetEstateStatsById(gomock.Any(), "1", repository.StatsOptions{}).Return(repository.EstateStats{Count: 3, MaxHeight: 20, MinHeight: 5, MedianHeight: 15}, nil)
 
-	h.GetEstateIdStats(c, uuid.Nil)
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{}))
 
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Contains(t, rec.Body.String(), `"count":3`)
@@ -302,10 +436,10 @@ func TestGetEstateStats_NoTreesFound(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
-	mockRepo.EXPECT().GetEstateStatsById("1").Return(repository.EstateStats{Count: 0, MaxHeight: 0, MinHeight: 0, MedianHeight: 0}, nil)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetEstateStatsById(gomock.Any(), "1", repository.StatsOptions{}).Return(repository.EstateStats{Count: 0, MaxHeight: 0, MinHeight: 0, MedianHeight: 0}, nil)
 
-	h.GetEstateIdStats(c, uuid.Nil)
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{}))
 
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Contains(t, rec.Body.String(), `"count":0`)
@@ -331,9 +465,9 @@ func TestGetEstateStats_EstateNotFound(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "", Width: 0, Length: 0}, sql.ErrNoRows)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "", Width: 0, Length: 0}, repository.ErrEstateNotFound)
 
-	h.GetEstateIdStats(c, uuid.Nil)
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{}))
 
 	assert.Equal(t, http.StatusNotFound, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Estate not found")
@@ -356,10 +490,10 @@ func TestGetEstateStats_DatabaseError(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
-	mockRepo.EXPECT().GetEstateStatsById("1").Return(repository.EstateStats{}, repository.ErrDatabaseError)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetEstateStatsById(gomock.Any(), "1", repository.StatsOptions{}).Return(repository.EstateStats{}, errDatabase)
 
-	h.GetEstateIdStats(c, uuid.Nil)
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{}))
 
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Failed to retrieve estate stats")
@@ -383,10 +517,10 @@ func TestGetDronePlan_Success(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
-	mockRepo.EXPECT().GetDronePlanByEstateId("1").Return(repository.DronePlan{Distance: 200}, nil)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetDronePlanByEstateId(gomock.Any(), "1").Return(repository.DronePlan{Distance: 200}, nil)
 
-	h.GetEstateIdDronePlan(c, uuid.Nil)
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
 
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Contains(t, rec.Body.String(), `"distance":200`)
@@ -409,15 +543,15 @@ func TestGetDronePlan_EstateNotFound(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{}, sql.ErrNoRows)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, repository.ErrEstateNotFound)
 
-	h.GetEstateIdDronePlan(c, uuid.Nil)
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
 
 	assert.Equal(t, http.StatusNotFound, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Estate not found")
 }
 
-func TestGetDronePlan_DronePlanNotFound(t *testing.T) {
+func TestGetDronePlan_ComputedWhenNotCached(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan", nil)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -434,13 +568,122 @@ func TestGetDronePlan_DronePlanNotFound(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "1", Width: 10, Length: 5}, nil)
-	mockRepo.EXPECT().GetDronePlanByEstateId("1").Return(repository.DronePlan{}, sql.ErrNoRows)
+	trees := []repository.Tree{
+		{Id: "a", X: 1, Y: 2, Height: 10},
+		{Id: "b", X: 1, Y: 3, Height: 20},
+		{Id: "c", X: 1, Y: 4, Height: 10},
+	}
 
-	h.GetEstateIdDronePlan(c, uuid.Nil)
+	var committed bool
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 1, Length: 5}, nil)
+	mockRepo.EXPECT().GetDronePlanByEstateId(gomock.Any(), "1").Return(repository.DronePlan{}, repository.ErrDronePlanNotFound).Times(2)
+	expectTx(mockRepo, repository.TxOptions{}, &committed)
+	mockRepo.EXPECT().LockEstate(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 1, Length: 5}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(trees, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+	mockRepo.EXPECT().SaveDronePlan(gomock.Any(), "1", repository.DronePlan{Distance: 82}).Return(nil)
 
-	assert.Equal(t, http.StatusNotFound, rec.Code)
-	assert.Contains(t, rec.Body.String(), "Drone plan not found")
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"distance":82`)
+	assert.True(t, committed)
+}
+
+func TestGetDronePlan_StoredWhileWaitingForLock(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	var committed bool
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1}, nil)
+	gomock.InOrder(
+		mockRepo.EXPECT().GetDronePlanByEstateId(gomock.Any(), "1").Return(repository.DronePlan{}, repository.ErrDronePlanNotFound),
+		mockRepo.EXPECT().GetDronePlanByEstateId(gomock.Any(), "1").Return(repository.DronePlan{Distance: 99}, nil),
+	)
+	expectTx(mockRepo, repository.TxOptions{}, &committed)
+	mockRepo.EXPECT().LockEstate(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1}, nil)
+
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"distance":99`)
+	assert.True(t, committed)
+}
+
+func TestGetDronePlan_CacheWriteFailure(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	var committed bool
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1}, nil)
+	mockRepo.EXPECT().GetDronePlanByEstateId(gomock.Any(), "1").Return(repository.DronePlan{}, repository.ErrDronePlanNotFound).Times(2)
+	expectTx(mockRepo, repository.TxOptions{}, &committed)
+	mockRepo.EXPECT().LockEstate(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(nil, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+	mockRepo.EXPECT().SaveDronePlan(gomock.Any(), "1", repository.DronePlan{Distance: 42}).Return(errDatabase)
+
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"distance":42`)
+	assert.False(t, committed)
+}
+
+func TestGetDronePlan_TreesDatabaseError(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	var committed bool
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 5}, nil)
+	mockRepo.EXPECT().GetDronePlanByEstateId(gomock.Any(), "1").Return(repository.DronePlan{}, repository.ErrDronePlanNotFound).Times(2)
+	expectTx(mockRepo, repository.TxOptions{}, &committed)
+	mockRepo.EXPECT().LockEstate(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 5}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(nil, errDatabase)
+
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Failed to retrieve drone plans")
+	assert.False(t, committed)
 }
 
 func TestGetDronePlan_DatabaseError(t *testing.T) {
@@ -460,11 +703,2964 @@ func TestGetDronePlan_DatabaseError(t *testing.T) {
 		Repository: mockRepo,
 	}
 
-	mockRepo.EXPECT().GetEstateById("1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
-	mockRepo.EXPECT().GetDronePlanByEstateId("1").Return(repository.DronePlan{}, repository.ErrDatabaseError)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetDronePlanByEstateId(gomock.Any(), "1").Return(repository.DronePlan{}, errDatabase)
 
-	h.GetEstateIdDronePlan(c, uuid.Nil)
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
 
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Failed to retrieve drone plans")
 }
+
+func TestGetDronePlan_MaxDistance(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan?max_distance=60", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	trees := []repository.Tree{
+		{Id: "a", X: 1, Y: 2, Height: 10},
+		{Id: "b", X: 1, Y: 3, Height: 20},
+		{Id: "c", X: 1, Y: 4, Height: 10},
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 1, Length: 5}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(trees, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	maxDistance := 60
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{MaxDistance: &maxDistance}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"distance":32`)
+	assert.Contains(t, rec.Body.String(), `"rest":{"x":1,"y":2}`)
+}
+
+func TestGetDronePlan_InvalidMaxDistance(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan?max_distance=0", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	maxDistance := 0
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{MaxDistance: &maxDistance}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "max_distance must be greater than 0")
+}
+
+func TestGetDronePlan_ExportGeoJSON(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan", nil)
+	req.Header.Set(echo.HeaderAccept, "application/geo+json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	geo := &repository.GeoReference{Latitude: -6.2, Longitude: 106.8, Bearing: 90}
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1, GeoReference: geo}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(nil, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/geo+json", rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), "drone-plan-1.geojson")
+	assert.Contains(t, rec.Body.String(), `"type":"LineString"`)
+}
+
+func TestGetDronePlan_ExportWithoutGeoReference(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan?format=kml", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1}, nil)
+
+	format := generated.Kml
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{Format: &format}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Estate has no geo-reference")
+}
+
+func TestGetDronePlan_Pattern(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan?pattern=columns&corner=ne", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	// Columns of a 5x1 estate are single plots: 4 hops of 10m, takeoff and landing
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(nil, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	pattern, corner := generated.Columns, generated.Ne
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{Pattern: &pattern, Corner: &corner}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"distance":42`)
+}
+
+func TestGetDronePlan_UnknownPattern(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan?pattern=hilbert", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	pattern := generated.GetEstateIdDronePlanParamsPattern("hilbert")
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{Pattern: &pattern}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Unsupported pattern or corner")
+}
+
+func TestGetDronePlan_SmoothMode(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan?mode=smooth", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	trees := []repository.Tree{
+		{Id: "a", X: 1, Y: 1, Height: 10},
+		{Id: "b", X: 3, Y: 1, Height: 20},
+	}
+
+	// The naive distance comes from the cache
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(trees, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+	mockRepo.EXPECT().GetDronePlanByEstateId(gomock.Any(), "1").Return(repository.DronePlan{Distance: 102}, nil)
+
+	mode := generated.Smooth
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{Mode: &mode}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"distance":82`)
+	assert.Contains(t, rec.Body.String(), `"naive_distance":102`)
+}
+
+func TestGetDronePlan_SmoothModeWithMaxDistance(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan?mode=smooth&max_distance=100", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mode, maxDistance := generated.Smooth, 100
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{Mode: &mode, MaxDistance: &maxDistance}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// 5. Get drone missions test files
+func TestGetDronePlanMissions_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/missions?battery_capacity=60", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	trees := []repository.Tree{
+		{Id: "a", X: 1, Y: 2, Height: 10},
+		{Id: "b", X: 1, Y: 3, Height: 20},
+		{Id: "c", X: 1, Y: 4, Height: 10},
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 1, Length: 5}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(trees, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	serve(c, h.GetEstateIdDronePlanMissions(c, uuid.Nil, generated.GetEstateIdDronePlanMissionsParams{BatteryCapacity: 60}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"distance":168`)
+	assert.Contains(t, rec.Body.String(), `{"start":{"x":1,"y":1},"end":{"x":1,"y":2},"distance":32,"plots":2}`)
+	assert.Contains(t, rec.Body.String(), `{"start":{"x":1,"y":4},"end":{"x":1,"y":5},"distance":32,"plots":1}`)
+}
+
+func TestGetDronePlanMissions_BatteryTooSmall(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/missions?battery_capacity=1", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(nil, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	serve(c, h.GetEstateIdDronePlanMissions(c, uuid.Nil, generated.GetEstateIdDronePlanMissionsParams{BatteryCapacity: 1}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Battery capacity too small")
+}
+
+func TestGetDronePlanMissions_TooManyMissions(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/missions?battery_capacity=23", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 1000, Length: 1000}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(nil, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	serve(c, h.GetEstateIdDronePlanMissions(c, uuid.Nil, generated.GetEstateIdDronePlanMissionsParams{BatteryCapacity: 23}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "in 10000 missions")
+}
+
+func TestGetDronePlanMissions_EstateNotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/missions?battery_capacity=100", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, repository.ErrEstateNotFound)
+
+	serve(c, h.GetEstateIdDronePlanMissions(c, uuid.Nil, generated.GetEstateIdDronePlanMissionsParams{BatteryCapacity: 100}))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Estate not found")
+}
+
+// 6. Get drone path test files
+func TestGetDronePlanPath_Paginated(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/path?offset=2&limit=3", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	trees := []repository.Tree{
+		{Id: "a", X: 1, Y: 2, Height: 10},
+		{Id: "b", X: 1, Y: 3, Height: 20},
+		{Id: "c", X: 1, Y: 4, Height: 10},
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 1, Length: 5}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(trees, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	// The cursor of the second waypoint, the climb over the first plot
+	cursor, limit := 3, 3
+	serve(c, h.GetEstateIdDronePlanPath(c, uuid.Nil, generated.GetEstateIdDronePlanPathParams{Cursor: &cursor, Limit: &limit}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"waypoints":[{"x":0,"y":10,"altitude":11},{"x":0,"y":10,"altitude":21},{"x":0,"y":30,"altitude":21}]`)
+	assert.Contains(t, rec.Body.String(), `"next_cursor":10`)
+}
+
+func TestGetDronePlanPath_LastPage(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/path?cursor=1", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 1, Length: 5}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(nil, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	cursor := 1
+	serve(c, h.GetEstateIdDronePlanPath(c, uuid.Nil, generated.GetEstateIdDronePlanPathParams{Cursor: &cursor}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"waypoints":[{"x":0,"y":40,"altitude":1},{"x":0,"y":40,"altitude":0}]`)
+	assert.NotContains(t, rec.Body.String(), "next_cursor")
+}
+
+func TestGetDronePlanPath_InvalidLimit(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/path?limit=0", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	limit := 0
+	serve(c, h.GetEstateIdDronePlanPath(c, uuid.Nil, generated.GetEstateIdDronePlanPathParams{Limit: &limit}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetDronePlanPath_FollowsNextCursor(t *testing.T) {
+	e := echo.New()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	trees := []repository.Tree{
+		{Id: "a", X: 2, Y: 1, Height: 3},
+		{Id: "b", X: 3, Y: 2, Height: 5},
+		{Id: "c", X: 1, Y: 3, Height: 12},
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 4, Length: 3}, nil).AnyTimes()
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(trees, nil).AnyTimes()
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound).AnyTimes()
+
+	page := func(params generated.GetEstateIdDronePlanPathParams) (response struct {
+		Waypoints  []planner.Waypoint `json:"waypoints"`
+		NextCursor *int               `json:"next_cursor"`
+	}) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/path", nil), rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		serve(c, h.GetEstateIdDronePlanPath(c, uuid.Nil, params))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response
+	}
+
+	whole := page(generated.GetEstateIdDronePlanPathParams{})
+	assert.Nil(t, whole.NextCursor)
+
+	// Pages of 2 waypoints add up to the whole path
+	var waypoints []planner.Waypoint
+	limit := 2
+	params := generated.GetEstateIdDronePlanPathParams{Limit: &limit}
+	for {
+		response := page(params)
+		waypoints = append(waypoints, response.Waypoints...)
+		if response.NextCursor == nil {
+			break
+		}
+		params.Cursor = response.NextCursor
+	}
+	assert.Equal(t, whole.Waypoints, waypoints)
+}
+
+func TestGetDronePlanPath_CursorPastLanding(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/path?cursor=16", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 1, Length: 5}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(nil, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	cursor := 16
+	serve(c, h.GetEstateIdDronePlanPath(c, uuid.Nil, generated.GetEstateIdDronePlanPathParams{Cursor: &cursor}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"cursor"`)
+}
+
+// 7. Drone plan patterns test files
+func TestGetDronePlanPatterns_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/patterns", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	trees := []repository.Tree{
+		{Id: "a", X: 1, Y: 1, Height: 20},
+		{Id: "b", X: 2, Y: 1, Height: 20},
+		{Id: "c", X: 3, Y: 1, Height: 20},
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 3, Length: 3}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(trees, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	serve(c, h.GetEstateIdDronePlanPatterns(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"recommended":{"pattern":"rows","corner":"sw","distance":122}`)
+	assert.Contains(t, rec.Body.String(), `"pattern":"spiral"`)
+}
+
+// 8. Drone config test files
+func TestGetDroneConfig_Default(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-config", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	serve(c, h.GetEstateIdDroneConfig(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `{"plot_size":10,"clearance":1,"takeoff_altitude":0}`)
+}
+
+func TestPutDroneConfig_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/estate/1/drone-config", strings.NewReader(`{"plot_size": 5, "clearance": 3}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().SaveDroneConfig(gomock.Any(), "1", repository.DroneConfig{PlotSize: 5, Clearance: 3, TakeoffAltitude: 0}).Return(nil)
+
+	serve(c, h.PutEstateIdDroneConfig(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"plot_size":5`)
+}
+
+func TestPutDroneConfig_InvalidPlotSize(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/estate/1/drone-config", strings.NewReader(`{"plot_size": 0}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	serve(c, h.PutEstateIdDroneConfig(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Plot size must be within 1 to 100 meters")
+}
+
+func TestGetDronePlan_UsesDroneConfig(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	var committed bool
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1}, nil)
+	mockRepo.EXPECT().GetDronePlanByEstateId(gomock.Any(), "1").Return(repository.DronePlan{}, repository.ErrDronePlanNotFound).Times(2)
+	expectTx(mockRepo, repository.TxOptions{}, &committed)
+	mockRepo.EXPECT().LockEstate(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return(nil, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{PlotSize: 5, Clearance: 3}, nil)
+	mockRepo.EXPECT().SaveDronePlan(gomock.Any(), "1", repository.DronePlan{Distance: 26}).Return(nil)
+
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"distance":26`)
+	assert.True(t, committed)
+}
+
+// 9. Estate management test files
+
+func TestListEstates_Paginated(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate?limit=2&order=asc", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	estates := []repository.Estate{
+		{Id: "1", Width: 10, Length: 10, TreeCount: 3},
+		{Id: "2", Width: 5, Length: 1},
+		{Id: "3", Width: 1, Length: 1},
+	}
+	mockRepo.EXPECT().ListEstates(gomock.Any(), 0, 3, true).Return(estates, nil)
+
+	limit, order := 2, generated.Asc
+	serve(c, h.GetEstate(c, generated.GetEstateParams{Limit: &limit, Order: &order}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":"1","width":10,"length":10,"tree_count":3`)
+	assert.Contains(t, rec.Body.String(), `"id":"2"`)
+	assert.NotContains(t, rec.Body.String(), `"id":"3"`)
+	assert.Contains(t, rec.Body.String(), `"next_offset":2`)
+}
+
+func TestListEstates_Empty(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().ListEstates(gomock.Any(), 0, 21, false).Return(nil, nil)
+
+	serve(c, h.GetEstate(c, generated.GetEstateParams{}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"estates":[]`)
+	assert.NotContains(t, rec.Body.String(), "next_offset")
+}
+
+func TestListEstates_InvalidLimit(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate?limit=101", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	limit := 101
+	serve(c, h.GetEstate(c, generated.GetEstateParams{Limit: &limit}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetEstate_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 20}, nil)
+	mockRepo.EXPECT().CountTreesByEstateId(gomock.Any(), "1").Return(4, nil)
+
+	serve(c, h.GetEstateId(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"width":10,"length":20,"tree_count":4`)
+}
+
+func TestGetEstate_EstateNotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, repository.ErrEstateNotFound)
+
+	serve(c, h.GetEstateId(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestPatchEstate_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1", strings.NewReader(`{"length": 30}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 20}, nil)
+	mockRepo.EXPECT().UpdateEstate(gomock.Any(), repository.Estate{Id: "1", Width: 10, Length: 30}).Return(nil)
+	mockRepo.EXPECT().CountTreesByEstateId(gomock.Any(), "1").Return(4, nil)
+
+	serve(c, h.PatchEstateId(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"width":10,"length":30,"tree_count":4`)
+}
+
+func TestPatchEstate_SetsGeoReference(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1", strings.NewReader(`{"latitude": -6.2, "longitude": 106.8}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	geo := &repository.GeoReference{Latitude: -6.2, Longitude: 106.8, Bearing: 90}
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 20}, nil)
+	mockRepo.EXPECT().UpdateEstate(gomock.Any(), repository.Estate{Id: "1", Width: 10, Length: 20, GeoReference: geo}).Return(nil)
+	mockRepo.EXPECT().CountTreesByEstateId(gomock.Any(), "1").Return(0, nil)
+
+	serve(c, h.PatchEstateId(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"geo_reference":{"latitude":-6.2,"longitude":106.8,"bearing":90}`)
+}
+
+func TestPatchEstate_KeepsGeoReferenceLeftOut(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1", strings.NewReader(`{"bearing": 45}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	estate := repository.Estate{Id: "1", Width: 10, Length: 20, GeoReference: &repository.GeoReference{Latitude: -6.2, Longitude: 106.8, Bearing: 90}}
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(estate, nil)
+	mockRepo.EXPECT().UpdateEstate(gomock.Any(), repository.Estate{Id: "1", Width: 10, Length: 20, GeoReference: &repository.GeoReference{Latitude: -6.2, Longitude: 106.8, Bearing: 45}}).Return(nil)
+	mockRepo.EXPECT().CountTreesByEstateId(gomock.Any(), "1").Return(0, nil)
+
+	serve(c, h.PatchEstateId(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"geo_reference":{"latitude":-6.2,"longitude":106.8,"bearing":45}`)
+}
+
+func TestPatchEstate_GeoReferenceNeedsLatitudeAndLongitude(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1", strings.NewReader(`{"latitude": -6.2}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 20}, nil)
+
+	serve(c, h.PatchEstateId(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Latitude and Longitude must be set together")
+}
+
+func TestPatchEstate_OrphansTrees(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1", strings.NewReader(`{"width": 2}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 20}, nil)
+	mockRepo.EXPECT().UpdateEstate(gomock.Any(), repository.Estate{Id: "1", Width: 2, Length: 20}).Return(repository.ErrTreesOutOfBounds)
+
+	serve(c, h.PatchEstateId(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Estate cannot be shrunk past its trees")
+}
+
+func TestPatchEstate_InvalidInput(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1", strings.NewReader(`{"width": 0}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	serve(c, h.PatchEstateId(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDeleteEstate_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/estate/1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().DeleteEstate(gomock.Any(), "1").Return(nil)
+
+	serve(c, h.DeleteEstateId(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestDeleteEstate_EstateNotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/estate/1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().DeleteEstate(gomock.Any(), "1").Return(repository.ErrEstateNotFound)
+
+	serve(c, h.DeleteEstateId(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// 10. Tree management test files
+
+func TestListTrees_Filtered(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/trees?min_height=5&x1=1&y1=1&x2=3&y2=3&limit=1", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	minHeight, limit := 5, 1
+	x1, y1, x2, y2 := 1, 1, 3, 3
+	filter := repository.TreeFilter{MinHeight: &minHeight, Bounds: &repository.Bounds{X1: 1, Y1: 1, X2: 3, Y2: 3}}
+	trees := []repository.Tree{
+		{Id: "a", X: 2, Y: 1, Height: 10},
+		{Id: "b", X: 3, Y: 3, Height: 5},
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().ListTrees(gomock.Any(), "1", filter, 0, 2).Return(trees, nil)
+
+	serve(c, h.GetEstateIdTrees(c, uuid.Nil, generated.GetEstateIdTreesParams{Limit: &limit, MinHeight: &minHeight, X1: &x1, Y1: &y1, X2: &x2, Y2: &y2}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"trees":[{"id":"a","x":2,"y":1,"height":10,`)
+	assert.NotContains(t, rec.Body.String(), `"id":"b"`)
+	assert.Contains(t, rec.Body.String(), `"next_offset":1`)
+}
+
+func TestListTrees_IncompleteBounds(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/trees?x1=1&y1=1", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	x1, y1 := 1, 1
+	serve(c, h.GetEstateIdTrees(c, uuid.Nil, generated.GetEstateIdTreesParams{X1: &x1, Y1: &y1}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetTree_TreeNotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/tree/a", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "a")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetTreeById(gomock.Any(), "1", "a").Return(repository.Tree{}, repository.ErrTreeNotFound)
+
+	serve(c, h.GetEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Tree not found")
+}
+
+func TestPatchTree_Move(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1/tree/a", strings.NewReader(`{"x": 4}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "a")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetTreeById(gomock.Any(), "1", "a").Return(repository.Tree{Id: "a", X: 2, Y: 3, Height: 10}, nil)
+	mockRepo.EXPECT().UpdateTree(gomock.Any(), "1", repository.Tree{Id: "a", X: 4, Y: 3, Height: 10}).Return(repository.Tree{Id: "a", X: 4, Y: 3, Height: 10}, nil)
+
+	serve(c, h.PatchEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `{"id":"a","x":4,"y":3,"height":10,`)
+}
+
+func TestPatchTree_CoordinatesOutOfBounds(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1/tree/a", strings.NewReader(`{"y": 11}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "a")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetTreeById(gomock.Any(), "1", "a").Return(repository.Tree{Id: "a", X: 2, Y: 3, Height: 10}, nil)
+
+	serve(c, h.PatchEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Coordinates out of bounds")
+}
+
+func TestPatchTree_PlotAlreadyHasTree(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1/tree/a", strings.NewReader(`{"x": 1, "y": 1}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "a")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetTreeById(gomock.Any(), "1", "a").Return(repository.Tree{Id: "a", X: 2, Y: 3, Height: 10}, nil)
+	mockRepo.EXPECT().UpdateTree(gomock.Any(), "1", repository.Tree{Id: "a", X: 1, Y: 1, Height: 10}).Return(repository.Tree{}, repository.ErrPlotOccupied)
+
+	serve(c, h.PatchEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Plot already has a tree")
+}
+
+func TestPatchTree_InvalidHeight(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1/tree/a", strings.NewReader(`{"height": 31}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "a")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	serve(c, h.PatchEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDeleteTree_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/estate/1/tree/a", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "a")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().DeleteTree(gomock.Any(), "1", "a").Return(nil)
+
+	serve(c, h.DeleteEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestDeleteTree_TreeNotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/estate/1/tree/a", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "a")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().DeleteTree(gomock.Any(), "1", "a").Return(repository.ErrTreeNotFound)
+
+	serve(c, h.DeleteEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// 11. Bulk import test files
+
+func TestImportTrees_CSVBestEffort(t *testing.T) {
+	e := echo.New()
+	body := "x,y,height\n1,1,10\n2,1,40\n11,1,5\n1,1,7\n3,x,5\n3,1,20\n"
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/trees/bulk?mode=best_effort", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, "text/csv")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	trees := []repository.Tree{{X: 1, Y: 1, Height: 10}, {X: 3, Y: 1, Height: 20}}
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().ImportTrees(gomock.Any(), "1", trees, false).Return([]repository.ImportedTree{{Id: "a"}, {Occupied: true}}, nil)
+
+	mode := generated.BestEffort
+	serve(c, h.PostEstateIdTreesBulk(c, uuid.Nil, generated.PostEstateIdTreesBulkParams{Mode: &mode}))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"accepted":1`)
+	assert.Contains(t, rec.Body.String(), `"rejected":5`)
+	assert.Contains(t, rec.Body.String(), `{"row":1,"id":"a"}`)
+	assert.Contains(t, rec.Body.String(), `{"row":2,"error":"invalid_height"}`)
+	assert.Contains(t, rec.Body.String(), `{"row":3,"error":"out_of_bounds"}`)
+	assert.Contains(t, rec.Body.String(), `{"row":4,"error":"duplicate_plot"}`)
+	assert.Contains(t, rec.Body.String(), `{"row":5,"error":"invalid_row"}`)
+	assert.Contains(t, rec.Body.String(), `{"row":6,"error":"duplicate_plot"}`)
+}
+
+func TestImportTrees_JSONAtomic(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/trees/bulk", strings.NewReader(`[{"x": 1, "y": 1, "height": 10}, {"x": 2, "y": 1, "height": 20}]`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	trees := []repository.Tree{{X: 1, Y: 1, Height: 10}, {X: 2, Y: 1, Height: 20}}
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().ImportTrees(gomock.Any(), "1", trees, true).Return([]repository.ImportedTree{{Id: "a"}, {Id: "b"}}, nil)
+
+	serve(c, h.PostEstateIdTreesBulk(c, uuid.Nil, generated.PostEstateIdTreesBulkParams{}))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"accepted":2`)
+	assert.Contains(t, rec.Body.String(), `{"row":2,"id":"b"}`)
+}
+
+func TestImportTrees_AtomicInvalidRow(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/trees/bulk", strings.NewReader(`[{"x": 1, "y": 1, "height": 10}, {"x": 2, "y": 1, "height": 0}]`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+
+	serve(c, h.PostEstateIdTreesBulk(c, uuid.Nil, generated.PostEstateIdTreesBulkParams{}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Body.String(), `"code":"import_rejected"`)
+	assert.Contains(t, rec.Body.String(), `"report":{"accepted":0,`)
+	assert.Contains(t, rec.Body.String(), `{"row":1}`)
+	assert.Contains(t, rec.Body.String(), `{"row":2,"error":"invalid_height"}`)
+}
+
+func TestImportTrees_AtomicPlotOccupied(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/trees/bulk?mode=atomic", strings.NewReader(`[{"x": 1, "y": 1, "height": 10}, {"x": 2, "y": 1, "height": 20}]`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().ImportTrees(gomock.Any(), "1", gomock.Any(), true).Return([]repository.ImportedTree{{}, {Occupied: true}}, nil)
+
+	mode := generated.Atomic
+	serve(c, h.PostEstateIdTreesBulk(c, uuid.Nil, generated.PostEstateIdTreesBulkParams{Mode: &mode}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Body.String(), `"code":"import_rejected"`)
+	assert.Contains(t, rec.Body.String(), `"report":{"accepted":0,`)
+	assert.Contains(t, rec.Body.String(), `{"row":2,"error":"duplicate_plot"}`)
+}
+
+func TestImportTrees_InvalidInput(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/trees/bulk", strings.NewReader(`{"x": 1}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	serve(c, h.PostEstateIdTreesBulk(c, uuid.Nil, generated.PostEstateIdTreesBulkParams{}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invalid input")
+}
+
+func TestImportTrees_TooManyRows(t *testing.T) {
+	e := echo.New()
+	body := strings.Repeat("1,1,10\n", 10001)
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/trees/bulk", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, "text/csv")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	serve(c, h.PostEstateIdTreesBulk(c, uuid.Nil, generated.PostEstateIdTreesBulkParams{}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "1 to 10000 trees can be imported at once")
+}
+
+func TestImportTrees_BodyTooLarge(t *testing.T) {
+	e := echo.New()
+	body := "[" + strings.Repeat(" ", maxBulkBytes) + "]"
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/trees/bulk", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	serve(c, h.PostEstateIdTreesBulk(c, uuid.Nil, generated.PostEstateIdTreesBulkParams{}))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"body_too_large"`)
+}
+
+// 12. Tree export test files
+
+func TestExportTrees_NDJSON(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/trees/export", nil)
+	req.Header.Set(echo.HeaderAccept, "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().StreamTrees(gomock.Any(), "1", gomock.Any()).DoAndReturn(func(ctx context.Context, estateId string, visit func(tree repository.Tree) error) error {
+		if err := visit(repository.Tree{Id: "a", X: 1, Y: 1, Height: 10}); err != nil {
+			return err
+		}
+		return visit(repository.Tree{Id: "b", X: 2, Y: 1, Height: 20})
+	})
+
+	serve(c, h.GetEstateIdTreesExport(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), `filename="trees-1.ndjson"`)
+	assert.Equal(t, 2, strings.Count(rec.Body.String(), "\n"))
+	assert.Contains(t, rec.Body.String(), `{"id":"b","x":2,"y":1,"height":20,`)
+}
+
+func TestExportTrees_NotAcceptable(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/trees/export", nil)
+	req.Header.Set(echo.HeaderAccept, "application/xml")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	serve(c, h.GetEstateIdTreesExport(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+}
+
+func TestExportTrees_EstateNotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/trees/export", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, repository.ErrEstateNotFound)
+
+	serve(c, h.GetEstateIdTreesExport(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// 13. Tree history test files
+
+func TestRecordMeasurement_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree/2/measurements", strings.NewReader(`{"height":12, "measured_at":"2024-05-01T00:00:00Z"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	measuredAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.EXPECT().RecordMeasurement(gomock.Any(), "1", "2", repository.Measurement{Height: 12, MeasuredAt: measuredAt, Source: "survey"}).
+		Return(repository.Measurement{Id: "3", Height: 12, MeasuredAt: measuredAt, Source: "survey"}, nil)
+
+	serve(c, h.PostEstateIdTreeTreeIdMeasurements(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":"3"`)
+	assert.Contains(t, rec.Body.String(), `"source":"survey"`)
+}
+
+func TestRecordMeasurement_FutureMeasuredAt(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree/2/measurements", strings.NewReader(`{"height":12, "measured_at":"2999-01-01T00:00:00Z"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	serve(c, h.PostEstateIdTreeTreeIdMeasurements(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "measured_at cannot be in the future")
+}
+
+func TestRecordMeasurement_TreeNotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree/2/measurements", strings.NewReader(`{"height":12}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().RecordMeasurement(gomock.Any(), "1", "2", gomock.Any()).Return(repository.Measurement{}, repository.ErrTreeNotFound)
+
+	serve(c, h.PostEstateIdTreeTreeIdMeasurements(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRecordMeasurement_BeforePlanting(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree/2/measurements", strings.NewReader(`{"height":12, "measured_at":"2020-01-01T00:00:00Z"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().RecordMeasurement(gomock.Any(), "1", "2", gomock.Any()).Return(repository.Measurement{}, repository.ErrMeasuredBeforePlanting)
+
+	serve(c, h.PostEstateIdTreeTreeIdMeasurements(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "measured_at cannot be before the tree was planted")
+}
+
+func TestTreeHistory_GrowthRate(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/tree/2/history", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	planted := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.EXPECT().GetTreeById(gomock.Any(), "1", "2").Return(repository.Tree{Id: "2", X: 1, Y: 1, Height: 9}, nil)
+	mockRepo.EXPECT().GetMeasurementsByTreeId(gomock.Any(), "1", "2").Return([]repository.Measurement{
+		{Id: "a", Height: 5, MeasuredAt: planted, Source: repository.SourcePlanting},
+		{Id: "b", Height: 9, MeasuredAt: planted.Add(2 * 365.25 * 24 * time.Hour), Source: "survey"},
+	}, nil)
+
+	serve(c, h.GetEstateIdTreeTreeIdHistory(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"source":"planting"`)
+	assert.Contains(t, rec.Body.String(), `"growth_rate":2`)
+}
+
+func TestGetEstateStats_AsOf(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?as_of=2024-01-01T00:00:00Z", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetEstateStatsAsOf(gomock.Any(), "1", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), repository.StatsOptions{}).Return(repository.EstateStats{Count: 2, MaxHeight: 8, MinHeight: 4, MedianHeight: 6}, nil)
+
+	asOf := "2024-01-01T00:00:00Z"
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{AsOf: &asOf}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"count":2`)
+	assert.Contains(t, rec.Body.String(), `"median":6`)
+}
+
+func TestGetEstateStats_AsOfDate(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?as_of=2026-06-30", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	// The whole day is reported, including the trees removed during it
+	endOfDay := time.Date(2026, 6, 30, 23, 59, 59, 999999000, time.UTC)
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetEstateStatsAsOf(gomock.Any(), "1", endOfDay, repository.StatsOptions{}).Return(repository.EstateStats{Count: 1, MaxHeight: 8, MinHeight: 8, MedianHeight: 8}, nil)
+
+	asOf := "2026-06-30"
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{AsOf: &asOf}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"count":1`)
+}
+
+func TestGetEstateStats_InvalidAsOf(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?as_of=last-quarter", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+
+	asOf := "last-quarter"
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{AsOf: &asOf}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// 14. Detailed stats test files
+
+func TestGetEstateStats_Include(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?include=mean,stddev,density&percentiles=25,75&bucket_width=10", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mean, stddev := 12.5, 2.5
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetEstateStatsById(gomock.Any(), "1", repository.StatsOptions{Mean: true, StdDev: true, Percentiles: []float64{25, 75}, BucketWidth: 10, Density: true}).
+		Return(repository.EstateStats{
+			Count: 2, MaxHeight: 15, MinHeight: 10, MedianHeight: 12,
+			MeanHeight: &mean, StdDevHeight: &stddev,
+			Percentiles: map[string]float64{"p25": 11.25, "p75": 13.75},
+			Histogram:   []repository.HistogramBucket{{From: 10, To: 20, Count: 2}},
+			Density:     &repository.Density{PerPlot: 0.02, PerHectare: 2},
+		}, nil)
+
+	include := []generated.StatsInclude{generated.Mean, generated.Stddev, generated.Density}
+	percentiles := []float64{25, 75}
+	bucketWidth := 10
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{Include: &include, Percentiles: &percentiles, BucketWidth: &bucketWidth}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"mean":12.5`)
+	assert.Contains(t, rec.Body.String(), `"stddev":2.5`)
+	assert.Contains(t, rec.Body.String(), `"percentiles":{"p25":11.25,"p75":13.75}`)
+	assert.Contains(t, rec.Body.String(), `"histogram":[{"from":10,"to":20,"count":2}]`)
+	assert.Contains(t, rec.Body.String(), `"density":{"per_plot":0.02,"per_hectare":2}`)
+}
+
+func TestGetEstateStats_IncludeDefaults(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?include=percentiles,histogram", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetEstateStatsById(gomock.Any(), "1", repository.StatsOptions{Percentiles: []float64{10, 90}, BucketWidth: 5}).Return(repository.EstateStats{}, nil)
+
+	include := []generated.StatsInclude{generated.Percentiles, generated.Histogram}
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{Include: &include}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `"mean"`)
+}
+
+func TestGetEstateStats_InvalidInclude(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?include=mode", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+
+	include := []generated.StatsInclude{"mode"}
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{Include: &include}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetEstateStats_InvalidPercentile(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?percentiles=150", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+
+	percentiles := []float64{150}
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{Percentiles: &percentiles}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "percentiles must be within 0 to 100")
+}
+
+func TestGetEstateStats_InvalidBucketWidth(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?bucket_width=0", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+
+	bucketWidth := 0
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{BucketWidth: &bucketWidth}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// 15. Region stats test files
+
+func TestGetEstateStats_Region(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?x1=2&y1=2&x2=5&y2=4&include=density", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 5}, nil)
+	mockRepo.EXPECT().GetEstateStatsById(gomock.Any(), "1", repository.StatsOptions{Bounds: &repository.Bounds{X1: 2, Y1: 2, X2: 5, Y2: 4}, Density: true}).
+		Return(repository.EstateStats{Count: 3, MaxHeight: 9, MinHeight: 3, MedianHeight: 6, Density: &repository.Density{PerPlot: 0.25, PerHectare: 25}}, nil)
+
+	include := []generated.StatsInclude{generated.Density}
+	x1, y1, x2, y2 := 2, 2, 5, 4
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{Include: &include, X1: &x1, Y1: &y1, X2: &x2, Y2: &y2}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"count":3`)
+	assert.Contains(t, rec.Body.String(), `"per_plot":0.25`)
+}
+
+func TestGetEstateStats_RegionOutOfEstate(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?x1=2&y1=2&x2=5&y2=6", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 5}, nil)
+
+	x1, y1, x2, y2 := 2, 2, 5, 6
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{X1: &x1, Y1: &y1, X2: &x2, Y2: &y2}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Coordinates out of bounds")
+}
+
+func TestGetEstateStats_RegionIncomplete(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats?x1=2&y1=2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 5}, nil)
+
+	x1, y1 := 2, 2
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{X1: &x1, Y1: &y1}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// 16. Zone test files
+
+func TestCreateZone_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/zone", strings.NewReader(`{"name":" Block A-3 ", "x1":1, "y1":1, "x2":5, "y2":3}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().CreateZone(gomock.Any(), "1", repository.Zone{Name: "Block A-3", Bounds: repository.Bounds{X1: 1, Y1: 1, X2: 5, Y2: 3}}).Return("2", nil)
+
+	serve(c, h.PostEstateIdZone(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":"2"`)
+}
+
+func TestCreateZone_MissingBounds(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/zone", strings.NewReader(`{"name":"Block A-3", "x1":1, "y1":1}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	serve(c, h.PostEstateIdZone(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCreateZone_OutOfBounds(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/zone", strings.NewReader(`{"name":"Block A-3", "x1":1, "y1":1, "x2":11, "y2":3}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+
+	serve(c, h.PostEstateIdZone(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Coordinates out of bounds")
+}
+
+func TestCreateZone_Overlaps(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/zone", strings.NewReader(`{"name":"Block A-3", "x1":1, "y1":1, "x2":5, "y2":3}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().CreateZone(gomock.Any(), "1", gomock.Any()).Return("", repository.ErrZoneOverlaps)
+
+	serve(c, h.PostEstateIdZone(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Zone overlaps another zone")
+}
+
+func TestCreateZone_NameTaken(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/zone", strings.NewReader(`{"name":"Block A-3", "x1":1, "y1":1, "x2":5, "y2":3}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().CreateZone(gomock.Any(), "1", gomock.Any()).Return("", repository.ErrZoneNameTaken)
+
+	serve(c, h.PostEstateIdZone(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Zone name already taken")
+}
+
+func TestCreateZone_ConflictWithConcurrentWrite(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/zone", strings.NewReader(`{"name":"Block A-3", "x1":1, "y1":1, "x2":5, "y2":3}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().CreateZone(gomock.Any(), "1", gomock.Any()).Return("", repository.ErrConflict)
+
+	serve(c, h.PostEstateIdZone(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"conflict"`)
+}
+
+func TestListZones_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/zones", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().ListZones(gomock.Any(), "1").Return([]repository.Zone{{Id: "2", Name: "Block A-3", Bounds: repository.Bounds{X1: 1, Y1: 1, X2: 5, Y2: 3}}}, nil)
+
+	serve(c, h.GetEstateIdZones(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `{"zones":[{"id":"2","name":"Block A-3","x1":1,"y1":1,"x2":5,"y2":3,`)
+}
+
+func TestGetZone_NotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/zone/2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "zoneId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetZoneById(gomock.Any(), "1", "2").Return(repository.Zone{}, repository.ErrZoneNotFound)
+
+	serve(c, h.GetEstateIdZoneZoneId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDeleteZone_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/estate/1/zone/2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "zoneId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().DeleteZone(gomock.Any(), "1", "2").Return(nil)
+
+	serve(c, h.DeleteEstateIdZoneZoneId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestZoneStats_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/zone/2/stats?include=mean", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "zoneId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mean := 7.5
+	bounds := repository.Bounds{X1: 1, Y1: 1, X2: 5, Y2: 3}
+	mockRepo.EXPECT().GetZoneById(gomock.Any(), "1", "2").Return(repository.Zone{Id: "2", Name: "Block A-3", Bounds: bounds}, nil)
+	mockRepo.EXPECT().GetEstateStatsById(gomock.Any(), "1", repository.StatsOptions{Bounds: &bounds, Mean: true}).
+		Return(repository.EstateStats{Count: 2, MaxHeight: 10, MinHeight: 5, MedianHeight: 7, MeanHeight: &mean}, nil)
+
+	include := []generated.StatsInclude{generated.Mean}
+	serve(c, h.GetEstateIdZoneZoneIdStats(c, uuid.Nil, uuid.Nil, generated.GetEstateIdZoneZoneIdStatsParams{Include: &include}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"count":2`)
+	assert.Contains(t, rec.Body.String(), `"mean":7.5`)
+}
+
+func TestZoneTrees_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/zone/2/trees?limit=1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "zoneId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	bounds := repository.Bounds{X1: 1, Y1: 1, X2: 5, Y2: 3}
+	mockRepo.EXPECT().GetZoneById(gomock.Any(), "1", "2").Return(repository.Zone{Id: "2", Name: "Block A-3", Bounds: bounds}, nil)
+	mockRepo.EXPECT().ListTrees(gomock.Any(), "1", repository.TreeFilter{Bounds: &bounds}, 0, 2).Return([]repository.Tree{{Id: "a", X: 1, Y: 1, Height: 5}, {Id: "b", X: 2, Y: 1, Height: 10}}, nil)
+
+	limit := 1
+	serve(c, h.GetEstateIdZoneZoneIdTrees(c, uuid.Nil, uuid.Nil, generated.GetEstateIdZoneZoneIdTreesParams{Limit: &limit}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"next_offset":1`)
+	assert.NotContains(t, rec.Body.String(), `"id":"b"`)
+}
+
+func TestZoneDronePlan_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/zone/2/drone-plan", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "zoneId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	// Only the tree at (3, 1) is in the zone, where it stands on its second plot
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 5, Length: 1}, nil)
+	mockRepo.EXPECT().GetZoneById(gomock.Any(), "1", "2").Return(repository.Zone{Id: "2", Name: "Block A-3", Bounds: repository.Bounds{X1: 2, Y1: 1, X2: 4, Y2: 1}}, nil)
+	mockRepo.EXPECT().GetTreesByEstateId(gomock.Any(), "1").Return([]repository.Tree{{Id: "a", X: 1, Y: 1, Height: 20}, {Id: "b", X: 3, Y: 1, Height: 5}}, nil)
+	mockRepo.EXPECT().GetDroneConfigByEstateId(gomock.Any(), "1").Return(repository.DroneConfig{}, repository.ErrDroneConfigNotFound)
+
+	serve(c, h.GetEstateIdZoneZoneIdDronePlan(c, uuid.Nil, uuid.Nil, generated.GetEstateIdZoneZoneIdDronePlanParams{}))
+
+	expected := planner.NewPlanner(planner.NewPlannerOptions{Width: 3, Length: 1, Trees: []planner.Tree{{X: 2, Y: 1, Height: 5}}, Config: planner.DefaultConfig()}).Distance()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), fmt.Sprintf(`"distance":%d`, expected))
+}
+
+// 17. Portfolio stats test files
+
+func TestGetStats_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetPortfolioStats(gomock.Any(), repository.PortfolioFilter{}, 5, 5).Return(repository.PortfolioStats{
+		EstateCount: 2, TreeCount: 3, TotalPlots: 150,
+		Heights:        repository.HeightStats{MaxHeight: 20, MinHeight: 5, MedianHeight: 10, MeanHeight: 11.67, Histogram: []repository.HistogramBucket{}},
+		TallestEstates: []repository.EstateRanking{{EstateId: "a", TreeCount: 1, MaxHeight: 20, Density: 0.02}},
+		DensestEstates: []repository.EstateRanking{{EstateId: "b", TreeCount: 2, MaxHeight: 10, Density: 0.2}},
+	}, nil)
+
+	serve(c, h.GetStats(c, generated.GetStatsParams{}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"estate_count":2,"tree_count":3,"total_plots":150`)
+	assert.Contains(t, rec.Body.String(), `"tallest_estates":[{"estate_id":"a"`)
+	assert.Contains(t, rec.Body.String(), `"densest_estates":[{"estate_id":"b"`)
+}
+
+func TestGetStats_Filter(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	estateId := uuid.New()
+	createdFrom := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.EXPECT().GetPortfolioStats(gomock.Any(), repository.PortfolioFilter{EstateIds: []string{estateId.String()}, CreatedFrom: &createdFrom}, 3, 10).Return(repository.PortfolioStats{}, nil)
+
+	top, bucketWidth := 3, 10
+	serve(c, h.GetStats(c, generated.GetStatsParams{EstateIds: &[]uuid.UUID{estateId}, CreatedFrom: &createdFrom, Top: &top, BucketWidth: &bucketWidth}))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestGetStats_InvalidCreationRange(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	createdFrom, createdTo := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	serve(c, h.GetStats(c, generated.GetStatsParams{CreatedFrom: &createdFrom, CreatedTo: &createdTo}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetStats_InvalidTop(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/stats?top=50", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	top := 50
+	serve(c, h.GetStats(c, generated.GetStatsParams{Top: &top}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// 18. Request deadline test files
+
+func TestGetTree_Timeout(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/tree/2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetTreeById(gomock.Any(), "1", "2").Return(repository.Tree{}, context.DeadlineExceeded)
+
+	serve(c, h.GetEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+}
+
+func TestGetTree_ClientGone(t *testing.T) {
+	e := echo.New()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/tree/2", nil).WithContext(canceled)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	// The repository may report the canceled query with an error of its own
+	mockRepo.EXPECT().GetTreeById(canceled, "1", "2").Return(repository.Tree{}, errDatabase)
+
+	serve(c, h.GetEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestRequestTimeout_SetsDeadline(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/tree/2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	var deadline time.Time
+	handler := RequestTimeout(time.Minute)(func(c echo.Context) error {
+		deadline, _ = c.Request().Context().Deadline()
+		return nil
+	})
+
+	assert.NoError(t, handler(c))
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+}
+
+func TestRequestTimeout_LiftedForExport(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/trees/export", nil)
+	req.Header.Set(echo.HeaderAccept, "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	// The estate is looked up within the deadline, the trees are streamed
+	// without it
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").DoAndReturn(func(ctx context.Context, estateId string) (repository.Estate, error) {
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		return repository.Estate{Id: "1", Width: 10, Length: 10}, nil
+	})
+	mockRepo.EXPECT().StreamTrees(gomock.Any(), "1", gomock.Any()).DoAndReturn(func(ctx context.Context, estateId string, visit func(tree repository.Tree) error) error {
+		_, ok := ctx.Deadline()
+		assert.False(t, ok)
+		return nil
+	})
+
+	handler := RequestTimeout(time.Minute)(func(c echo.Context) error {
+		return h.GetEstateIdTreesExport(c, uuid.Nil)
+	})
+
+	serve(c, handler(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+// 19. Repository error test files
+func TestGetTree_DatabaseUnavailable(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/tree/2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "2")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetTreeById(gomock.Any(), "1", "2").Return(repository.Tree{}, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+
+	serve(c, h.GetEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Service unavailable")
+}
+
+func TestAddTree_Conflict(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(`{"x": 1, "y": 1, "height": 10}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().AddTree(gomock.Any(), "1", 1, 1, 10).Return("", repository.ErrConflict)
+
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestUpdateTree_EstateDeletedMeanwhile(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/estate/1/tree/a", strings.NewReader(`{"height": 12}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "treeId")
+	c.SetParamValues("1", "a")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{Id: "1", Width: 10, Length: 10}, nil)
+	mockRepo.EXPECT().GetTreeById(gomock.Any(), "1", "a").Return(repository.Tree{Id: "a", X: 1, Y: 1, Height: 10}, nil)
+	mockRepo.EXPECT().UpdateTree(gomock.Any(), "1", repository.Tree{Id: "a", X: 1, Y: 1, Height: 12}).Return(repository.Tree{}, repository.ErrEstateNotFound)
+
+	serve(c, h.PatchEstateIdTreeTreeId(c, uuid.Nil, uuid.Nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Estate not found")
+}
+
+// 20. Problem response test files
+func TestAddTree_ProblemWithFieldErrors(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(`{"x": 1, "y": 1, "height": 40}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	h := &Server{}
+
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
+
+	var problem Problem
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, "urn:swpr-drone:problem:invalid_input", problem.Type)
+	assert.Equal(t, "Invalid input", problem.Title)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, "Height must be within 1 to 30 meters", problem.Detail)
+	assert.Equal(t, "/estate/1/tree", problem.Instance)
+	assert.Equal(t, "invalid_input", problem.Code)
+	assert.Equal(t, []FieldError{{Field: "height", Message: "Height must be within 1 to 30 meters"}}, problem.Errors)
+}
+
+func TestAddTree_ProblemWithFieldOfWrongType(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(`{"x": "invalid", "y": 1, "height": 10}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	h := &Server{}
+
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
+
+	var problem Problem
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, "invalid_input", problem.Code)
+	assert.Equal(t, []FieldError{{Field: "x", Message: "Must be an integer, not string"}}, problem.Errors)
+}
+
+func TestGetEstate_ProblemOfServerErrorHidesCause(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, fmt.Errorf("pq: password authentication failed"))
+
+	serve(c, h.GetEstateId(c, uuid.Nil))
+
+	var problem Problem
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, "internal", problem.Code)
+	assert.NotContains(t, rec.Body.String(), "password")
+}
+
+func TestErrorHandler_UnknownRoute(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/nowhere", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ErrorHandler(echo.ErrNotFound, c)
+
+	var problem Problem
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, "not_found", problem.Code)
+	assert.Equal(t, "Not Found", problem.Title)
+	assert.Equal(t, "/nowhere", problem.Instance)
+}
+
+func TestErrorHandler_UnexpectedError(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	ErrorHandler(fmt.Errorf("unexpected"), c)
+
+	var problem Problem
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, "internal", problem.Code)
+	assert.Equal(t, "Internal server error", problem.Detail)
+}
+
+func TestErrorHandler_AbortsCommittedResponse(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/trees/export", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	c.Response().WriteHeader(http.StatusOK)
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		ErrorHandler(fmt.Errorf("stream broken"), c)
+	})
+}
+
+// 21. Estate lookup failure test files
+func TestAddTree_EstateLookupTimedOut(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(`{"x": 1, "y": 1, "height": 10}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, fmt.Errorf("%w: canceling statement due to statement timeout", repository.ErrTimeout))
+
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Request timed out")
+}
+
+func TestGetEstateStats_EstateLookupTimedOut(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, fmt.Errorf("%w: canceling statement due to statement timeout", repository.ErrTimeout))
+
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{}))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Request timed out")
+}
+
+func TestGetDronePlan_EstateLookupTimedOut(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, fmt.Errorf("%w: canceling statement due to statement timeout", repository.ErrTimeout))
+
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Request timed out")
+}
+
+func TestAddTree_EstateLookupUnavailable(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(`{"x": 1, "y": 1, "height": 10}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Service unavailable")
+}
+
+func TestGetEstateStats_EstateLookupUnavailable(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{}))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Service unavailable")
+}
+
+func TestGetDronePlan_EstateLookupUnavailable(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Service unavailable")
+}
+
+func TestAddTree_EstateLookupFailed(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/estate/1/tree", strings.NewReader(`{"x": 1, "y": 1, "height": 10}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, errDatabase)
+
+	serve(c, h.PostEstateIdTree(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Failed to retrieve estate")
+}
+
+func TestGetEstateStats_EstateLookupFailed(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/stats", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, errDatabase)
+
+	serve(c, h.GetEstateIdStats(c, uuid.Nil, generated.GetEstateIdStatsParams{}))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Failed to retrieve estate")
+}
+
+func TestGetDronePlan_EstateLookupFailed(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, errDatabase)
+
+	serve(c, h.GetEstateIdDronePlan(c, uuid.Nil, generated.GetEstateIdDronePlanParams{}))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Failed to retrieve estate")
+}
+
+func TestGetDronePlanMissions_EstateLookupFailed(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/missions?battery_capacity=100", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, errDatabase)
+
+	serve(c, h.GetEstateIdDronePlanMissions(c, uuid.Nil, generated.GetEstateIdDronePlanMissionsParams{BatteryCapacity: 100}))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Failed to retrieve estate")
+}
+
+func TestGetDronePlanPath_EstateLookupFailed(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/path", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, errDatabase)
+
+	serve(c, h.GetEstateIdDronePlanPath(c, uuid.Nil, generated.GetEstateIdDronePlanPathParams{}))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Failed to retrieve estate")
+}
+
+func TestGetDronePlanPatterns_EstateLookupFailed(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/estate/1/drone-plan/patterns", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, errDatabase)
+
+	serve(c, h.GetEstateIdDronePlanPatterns(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Failed to retrieve estate")
+}
+
+func TestPutDroneConfig_EstateLookupFailed(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/estate/1/drone-config", strings.NewReader(`{"plot_size": 5}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	h := &Server{
+		Repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().GetEstateById(gomock.Any(), "1").Return(repository.Estate{}, errDatabase)
+
+	serve(c, h.PutEstateIdDroneConfig(c, uuid.Nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Failed to retrieve estate")
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/unklejo/swpr.drone/repository"
+)
+
+// MIMEApplicationProblemJSON is the content type of the error responses.
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// problemTypePrefix is followed by the code of a problem to make its type.
+const problemTypePrefix = "urn:swpr-drone:problem:"
+
+// Codes of the problems, which clients tell the problems apart by. Errors of
+// echo itself, e.g. an unknown route, get the HTTP status text as code.
+const (
+	codeInvalidInput     = "invalid_input"
+	codeOutOfBounds      = "out_of_bounds"
+	codePlotOccupied     = "plot_occupied"
+	codeNoGeoReference   = "no_geo_reference"
+	codeEstateNotFound   = "estate_not_found"
+	codeTreeNotFound     = "tree_not_found"
+	codeZoneNotFound     = "zone_not_found"
+	codeNotAcceptable    = "not_acceptable"
+	codeTreesOutOfBounds = "trees_out_of_bounds"
+	codeZonesOutOfBounds = "zones_out_of_bounds"
+	codeZoneOverlaps     = "zone_overlaps"
+	codeZoneNameTaken    = "zone_name_taken"
+	codeBodyTooLarge     = "body_too_large"
+	codeImportRejected   = "import_rejected"
+	codeConflict         = "conflict"
+	codeCanceled         = "canceled"
+	codeUnavailable      = "unavailable"
+	codeTimeout          = "timeout"
+	codeInternal         = "internal"
+)
+
+// problemTitles sum up the problem of each code, whatever its occurrence.
+var problemTitles = map[string]string{
+	codeInvalidInput:     "Invalid input",
+	codeOutOfBounds:      "Coordinates out of bounds",
+	codePlotOccupied:     "Plot already has a tree",
+	codeNoGeoReference:   "Estate has no geo-reference",
+	codeEstateNotFound:   "Estate not found",
+	codeTreeNotFound:     "Tree not found",
+	codeZoneNotFound:     "Zone not found",
+	codeNotAcceptable:    "Not acceptable",
+	codeTreesOutOfBounds: "Trees out of bounds",
+	codeZonesOutOfBounds: "Zones out of bounds",
+	codeZoneOverlaps:     "Zone overlaps another zone",
+	codeZoneNameTaken:    "Zone name already taken",
+	codeBodyTooLarge:     "Request body too large",
+	codeImportRejected:   "Bulk import rejected",
+	codeConflict:         "Conflict with a concurrent request",
+	codeCanceled:         "Request canceled",
+	codeUnavailable:      "Service unavailable",
+	codeTimeout:          "Request timed out",
+	codeInternal:         "Internal server error",
+}
+
+// Problem is an error response, as described by RFC 7807. Handlers return it
+// as their error, for ErrorHandler to respond with.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Errors lists the fields at fault when the input is invalid
+	Errors []FieldError `json:"errors,omitempty"`
+	// Report tells the outcome of every row of a rejected bulk import
+	Report *bulkTreesReport `json:"report,omitempty"`
+
+	// cause is the error behind a server error, which is logged but never
+	// sent
+	cause error
+}
+
+// FieldError tells what is wrong with a field of the input.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (p *Problem) Error() string {
+	if p.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", p.Code, p.Detail, p.cause)
+	}
+	return p.Code + ": " + p.Detail
+}
+
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
+
+func newProblem(status int, code, detail string) *Problem {
+	title, ok := problemTitles[code]
+	if !ok {
+		title = http.StatusText(status)
+	}
+	return &Problem{Type: problemTypePrefix + code, Title: title, Status: status, Detail: detail, Code: code}
+}
+
+// invalid is the problem of input failing validation, the fields at fault
+// sharing the detail as their message.
+func invalid(detail string, fields ...string) *Problem {
+	problem := newProblem(http.StatusBadRequest, codeInvalidInput, detail)
+	for _, field := range fields {
+		problem.Errors = append(problem.Errors, FieldError{Field: field, Message: detail})
+	}
+	return problem
+}
+
+// invalidBody is the problem of a request body that cannot be decoded. A
+// value of the wrong type is reported against its field.
+func invalidBody(err error) *Problem {
+	problem := newProblem(http.StatusBadRequest, codeInvalidInput, "Invalid input")
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		message := fmt.Sprintf("Must be %s, not %s", jsonType(typeErr.Type), typeErr.Value)
+		problem.Errors = []FieldError{{Field: typeErr.Field, Message: message}}
+	}
+	return problem
+}
+
+// jsonType names the JSON type that decodes into a Go type.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// serverError is the problem of a request that failed for reasons of the
+// server: 504 when the deadline of the request passed or the database timed
+// out, 503 when the request was canceled, e.g. by a client disconnect, or the
+// database is unavailable, 409 when the request conflicted with a concurrent
+// one, and 500 with message otherwise.
+func serverError(ctx echo.Context, err error, message string) *Problem {
+	var problem *Problem
+	cause := ctx.Request().Context().Err()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(cause, context.DeadlineExceeded):
+		problem = newProblem(http.StatusGatewayTimeout, codeTimeout, "Request timed out")
+	case errors.Is(err, context.Canceled) || errors.Is(cause, context.Canceled):
+		problem = newProblem(http.StatusServiceUnavailable, codeCanceled, "Request canceled")
+	case errors.Is(err, repository.ErrTimeout):
+		problem = newProblem(http.StatusGatewayTimeout, codeTimeout, "Request timed out")
+	case errors.Is(err, repository.ErrUnavailable):
+		problem = newProblem(http.StatusServiceUnavailable, codeUnavailable, "Service unavailable")
+	case errors.Is(err, repository.ErrConflict):
+		problem = newProblem(http.StatusConflict, codeConflict, "Request conflicted with a concurrent one, please retry")
+	default:
+		problem = newProblem(http.StatusInternalServerError, codeInternal, message)
+	}
+	problem.cause = err
+	return problem
+}
+
+// ErrorHandler responds to the errors returned by the handlers, and by echo
+// itself, with an application/problem+json body. Errors other than a Problem
+// or an echo.HTTPError are internal server errors. Server errors are logged
+// along with their cause.
+func ErrorHandler(err error, ctx echo.Context) {
+	// The response is on its way already, e.g. a streamed export, so the
+	// error is logged and the connection aborted, for the client not to take
+	// a cut off body for a complete one
+	if ctx.Response().Committed {
+		ctx.Logger().Error(err)
+		panic(http.ErrAbortHandler)
+	}
+
+	var problem *Problem
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &problem):
+	case errors.As(err, &httpErr):
+		problem = httpProblem(httpErr)
+	default:
+		problem = serverError(ctx, err, "Internal server error")
+	}
+
+	if problem.cause != nil {
+		ctx.Logger().Error(err)
+	}
+
+	response := *problem
+	response.Instance = ctx.Request().URL.Path
+
+	if ctx.Request().Method == http.MethodHead {
+		err = ctx.NoContent(response.Status)
+	} else {
+		ctx.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+		err = ctx.JSON(response.Status, response)
+	}
+	if err != nil {
+		ctx.Logger().Error(err)
+	}
+}
+
+// httpProblem turns an error of echo, e.g. an unknown route or a path
+// parameter of the wrong format, into a problem.
+func httpProblem(httpErr *echo.HTTPError) *Problem {
+	detail := fmt.Sprint(httpErr.Message)
+	if httpErr.Code == http.StatusBadRequest {
+		problem := invalidBody(httpErr)
+		problem.Detail = detail
+		return problem
+	}
+
+	code := codeInternal
+	if httpErr.Code != http.StatusInternalServerError {
+		code = strings.ToLower(strings.ReplaceAll(http.StatusText(httpErr.Code), " ", "_"))
+	}
+	problem := newProblem(httpErr.Code, code, detail)
+	if httpErr.Code >= http.StatusInternalServerError {
+		problem.cause = httpErr
+	}
+	return problem
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// requestContextKey holds the context of a request before RequestTimeout gave
+// it a deadline
+const requestContextKey = "handler.requestContext"
+
+// RequestTimeout gives every request a deadline, which the repository
+// queries made on its behalf are canceled at. The context of a request is
+// also canceled when the client disconnects.
+func RequestTimeout(timeout time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			parent := ctx.Request().Context()
+			deadline, cancel := context.WithTimeout(parent, timeout)
+			defer cancel()
+
+			ctx.Set(requestContextKey, parent)
+			ctx.SetRequest(ctx.Request().WithContext(deadline))
+			return next(ctx)
+		}
+	}
+}
+
+// stream lifts the deadline of RequestTimeout off a request about to stream
+// its response, which takes as long as there is data to send. The request is
+// still canceled when the client disconnects.
+func stream(ctx echo.Context) {
+	if parent, ok := ctx.Get(requestContextKey).(context.Context); ok {
+		ctx.SetRequest(ctx.Request().WithContext(parent))
+	}
+}
@@ -0,0 +1,11 @@
+package planner
+
+import "errors"
+
+var (
+	ErrBatteryTooSmall = errors.New("battery capacity too small to cover a single plot")
+	ErrUnknownFormat   = errors.New("unknown export format")
+	ErrUnknownPattern  = errors.New("unknown traversal pattern or corner")
+	ErrInvalidCursor   = errors.New("cursor past the end of the path")
+	ErrTooManySorties  = errors.New("route takes too many sorties")
+)
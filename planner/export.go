@@ -0,0 +1,210 @@
+package planner
+
+import (
+	"fmt"
+	"io"
+)
+
+// Formats the flight path can be exported to.
+const (
+	FormatGeoJSON = "geojson"
+	FormatKML     = "kml"
+	// FormatQGCPlan is the QGroundControl .plan mission file.
+	FormatQGCPlan = "plan"
+	// FormatMAVLink is the QGC WPL 110 MAVLink waypoint file.
+	FormatMAVLink = "mavlink"
+)
+
+// MAVLink commands and frames used by the mission files.
+const (
+	mavCmdNavWaypoint = 16
+	mavCmdNavLand     = 21
+	mavFrameGlobal    = 0
+	// Altitudes are relative to the home position, the ground of plot (1, 1)
+	mavFrameGlobalRelativeAlt = 3
+)
+
+var formats = map[string]struct {
+	contentType string
+	extension   string
+}{
+	FormatGeoJSON: {contentType: "application/geo+json", extension: "geojson"},
+	FormatKML:     {contentType: "application/vnd.google-earth.kml+xml", extension: "kml"},
+	FormatQGCPlan: {contentType: "application/json", extension: "plan"},
+	FormatMAVLink: {contentType: "text/plain; charset=utf-8", extension: "waypoints"},
+}
+
+// ContentType returns the media type and the file extension of an export
+// format, and false when the format is not supported.
+func ContentType(format string) (contentType, extension string, ok bool) {
+	f, ok := formats[format]
+	return f.contentType, f.extension, ok
+}
+
+// encoder writes a flight path in a file format. point is called for every
+// waypoint in order, with last set on the landing waypoint.
+type encoder interface {
+	header(w io.Writer) error
+	point(w io.Writer, index int, point GeoPoint, last bool) error
+	footer(w io.Writer) error
+}
+
+// Export writes the waypoints produced by path to w in the given format,
+// without holding the whole path in memory. It returns ErrUnknownFormat when
+// the format is not supported, and the error of path when it fails.
+func Export(w io.Writer, format string, ref GeoReference, path func(visit func(waypoint Waypoint) bool) error) (err error) {
+	var enc encoder
+	switch format {
+	case FormatGeoJSON:
+		enc = &geoJSONEncoder{}
+	case FormatKML:
+		enc = &kmlEncoder{}
+	case FormatQGCPlan:
+		enc = &qgcPlanEncoder{}
+	case FormatMAVLink:
+		enc = &mavlinkEncoder{}
+	default:
+		return ErrUnknownFormat
+	}
+
+	if err = enc.header(w); err != nil {
+		return err
+	}
+
+	// Hold back one waypoint to know which one is the landing
+	var previous GeoPoint
+	index := 0
+	pathErr := path(func(waypoint Waypoint) bool {
+		if index > 0 {
+			err = enc.point(w, index-1, previous, false)
+		}
+		previous = ref.Locate(waypoint)
+		index++
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if pathErr != nil {
+		return pathErr
+	}
+
+	if index > 0 {
+		if err = enc.point(w, index-1, previous, true); err != nil {
+			return err
+		}
+	}
+	return enc.footer(w)
+}
+
+// geoJSONEncoder writes the path as a single LineString feature.
+type geoJSONEncoder struct{}
+
+func (geoJSONEncoder) header(w io.Writer) error {
+	_, err := io.WriteString(w, `{"type":"Feature","properties":{"name":"Drone plan"},"geometry":{"type":"LineString","coordinates":[`)
+	return err
+}
+
+func (geoJSONEncoder) point(w io.Writer, index int, point GeoPoint, last bool) error {
+	separator := ","
+	if index == 0 {
+		separator = ""
+	}
+	_, err := fmt.Fprintf(w, "%s[%.8f,%.8f,%d]", separator, point.Longitude, point.Latitude, point.Altitude)
+	return err
+}
+
+func (geoJSONEncoder) footer(w io.Writer) error {
+	_, err := io.WriteString(w, "]}}\n")
+	return err
+}
+
+// kmlEncoder writes the path as a LineString placemark with altitudes
+// relative to the ground.
+type kmlEncoder struct{}
+
+func (kmlEncoder) header(w io.Writer) error {
+	_, err := io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document>
+<Placemark>
+<name>Drone plan</name>
+<LineString>
+<altitudeMode>relativeToGround</altitudeMode>
+<coordinates>
+`)
+	return err
+}
+
+func (kmlEncoder) point(w io.Writer, index int, point GeoPoint, last bool) error {
+	_, err := fmt.Fprintf(w, "%.8f,%.8f,%d\n", point.Longitude, point.Latitude, point.Altitude)
+	return err
+}
+
+func (kmlEncoder) footer(w io.Writer) error {
+	_, err := io.WriteString(w, `</coordinates>
+</LineString>
+</Placemark>
+</Document>
+</kml>
+`)
+	return err
+}
+
+// qgcPlanEncoder writes a QGroundControl mission. The takeoff waypoint is the
+// planned home position, and the drone lands on the last waypoint.
+type qgcPlanEncoder struct{}
+
+func (qgcPlanEncoder) header(w io.Writer) error {
+	_, err := io.WriteString(w, `{"fileType":"Plan","version":1,"groundStation":"QGroundControl","geoFence":{"circles":[],"polygons":[],"version":2},"rallyPoints":{"points":[],"version":2},"mission":{"version":2,"firmwareType":12,"vehicleType":2,"cruiseSpeed":15,"hoverSpeed":5,`)
+	return err
+}
+
+func (qgcPlanEncoder) point(w io.Writer, index int, point GeoPoint, last bool) error {
+	if index == 0 {
+		_, err := fmt.Fprintf(w, `"plannedHomePosition":[%.8f,%.8f,0],"items":[`, point.Latitude, point.Longitude)
+		return err
+	}
+
+	separator := ","
+	if index == 1 {
+		separator = ""
+	}
+	command := mavCmdNavWaypoint
+	if last {
+		command = mavCmdNavLand
+	}
+	_, err := fmt.Fprintf(w, `%s{"type":"SimpleItem","autoContinue":true,"command":%d,"doJumpId":%d,"frame":%d,"params":[0,0,0,null,%.8f,%.8f,%d]}`,
+		separator, command, index, mavFrameGlobalRelativeAlt, point.Latitude, point.Longitude, point.Altitude)
+	return err
+}
+
+func (qgcPlanEncoder) footer(w io.Writer) error {
+	_, err := io.WriteString(w, "]}}\n")
+	return err
+}
+
+// mavlinkEncoder writes a QGC WPL 110 waypoint file. Item 0 is the home
+// position, the drone lands on the last item.
+type mavlinkEncoder struct{}
+
+func (mavlinkEncoder) header(w io.Writer) error {
+	_, err := io.WriteString(w, "QGC WPL 110\n")
+	return err
+}
+
+func (mavlinkEncoder) point(w io.Writer, index int, point GeoPoint, last bool) error {
+	current, frame, command := 0, mavFrameGlobalRelativeAlt, mavCmdNavWaypoint
+	if index == 0 {
+		current, frame = 1, mavFrameGlobal
+	} else if last {
+		command = mavCmdNavLand
+	}
+	_, err := fmt.Fprintf(w, "%d\t%d\t%d\t%d\t0\t0\t0\t0\t%.8f\t%.8f\t%d\t1\n",
+		index, current, frame, command, point.Latitude, point.Longitude, point.Altitude)
+	return err
+}
+
+func (mavlinkEncoder) footer(w io.Writer) error {
+	return nil
+}
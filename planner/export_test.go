@@ -0,0 +1,122 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testReference = GeoReference{Latitude: -6.2, Longitude: 106.8, Bearing: 90}
+
+func testPath(visit func(waypoint Waypoint) bool) error {
+	return newTestPlanner(1, 5, []Tree{{X: 1, Y: 3, Height: 20}}).Path(context.Background(), visit)
+}
+
+func TestLocate_BearingOrientsTheAxes(t *testing.T) {
+	origin := testReference.Locate(Waypoint{})
+	east := testReference.Locate(Waypoint{X: 100})
+	north := testReference.Locate(Waypoint{Y: 100, Altitude: 5})
+
+	assert.Equal(t, GeoPoint{Latitude: -6.2, Longitude: 106.8}, origin)
+	assert.InDelta(t, -6.2, east.Latitude, 1e-9)
+	assert.Greater(t, east.Longitude, 106.8)
+	assert.Greater(t, north.Latitude, -6.2)
+	assert.InDelta(t, 106.8, north.Longitude, 1e-9)
+	assert.Equal(t, 5, north.Altitude)
+
+	// Rows laid northward move the y axis westward
+	rotated := GeoReference{Latitude: -6.2, Longitude: 106.8, Bearing: 0}.Locate(Waypoint{Y: 100})
+	assert.Less(t, rotated.Longitude, 106.8)
+}
+
+// haversine is the great circle distance between two points in meters.
+func haversine(from, to GeoPoint) float64 {
+	dLatitude, dLongitude := radians(to.Latitude-from.Latitude), radians(to.Longitude-from.Longitude)
+	a := math.Pow(math.Sin(dLatitude/2), 2) + math.Cos(radians(from.Latitude))*math.Cos(radians(to.Latitude))*math.Pow(math.Sin(dLongitude/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func TestLocate_KeepsDistancesOnTheLargestEstate(t *testing.T) {
+	// The far corner of a 50000 by 50000 estate of 10m plots, far north
+	ref := GeoReference{Latitude: 60, Longitude: 179, Bearing: 90}
+	corner := Waypoint{X: 499990, Y: 499990}
+
+	origin, located := ref.Locate(Waypoint{}), ref.Locate(corner)
+
+	assert.InDelta(t, math.Hypot(499990, 499990), haversine(origin, located), 0.01)
+	// Past the antimeridian
+	assert.Less(t, located.Longitude, 0.0)
+	assert.Greater(t, located.Longitude, -180.0)
+}
+
+func TestExport_GeoJSON(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Export(&buf, FormatGeoJSON, testReference, testPath))
+
+	var feature struct {
+		Type     string
+		Geometry struct {
+			Type        string
+			Coordinates [][3]float64
+		}
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &feature))
+	assert.Equal(t, "Feature", feature.Type)
+	assert.Equal(t, "LineString", feature.Geometry.Type)
+	assert.Len(t, feature.Geometry.Coordinates, len(collectPath(1, 5, []Tree{{X: 1, Y: 3, Height: 20}})))
+	assert.Equal(t, [3]float64{106.8, -6.2, 0}, feature.Geometry.Coordinates[0])
+}
+
+func TestExport_KML(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Export(&buf, FormatKML, testReference, testPath))
+
+	assert.Contains(t, buf.String(), "<altitudeMode>relativeToGround</altitudeMode>")
+	assert.Contains(t, buf.String(), "106.80000000,-6.20000000,0\n")
+	assert.True(t, strings.HasSuffix(buf.String(), "</kml>\n"))
+}
+
+func TestExport_QGCPlan(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Export(&buf, FormatQGCPlan, testReference, testPath))
+
+	var plan struct {
+		FileType string
+		Mission  struct {
+			PlannedHomePosition []float64
+			Items               []struct {
+				Command int
+				Frame   int
+				Params  []*float64
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &plan))
+	assert.Equal(t, "Plan", plan.FileType)
+	assert.Equal(t, []float64{-6.2, 106.8, 0}, plan.Mission.PlannedHomePosition)
+
+	items := plan.Mission.Items
+	assert.Equal(t, mavCmdNavWaypoint, items[0].Command)
+	assert.Equal(t, mavCmdNavLand, items[len(items)-1].Command)
+	assert.Equal(t, 1.0, *items[0].Params[6])
+}
+
+func TestExport_MAVLink(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Export(&buf, FormatMAVLink, testReference, testPath))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, "QGC WPL 110", lines[0])
+	assert.Equal(t, "0\t1\t0\t16\t0\t0\t0\t0\t-6.20000000\t106.80000000\t0\t1", lines[1])
+	assert.True(t, strings.HasPrefix(lines[len(lines)-1], "7\t0\t3\t21\t"))
+}
+
+func TestExport_UnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	assert.ErrorIs(t, Export(&buf, "shapefile", testReference, testPath), ErrUnknownFormat)
+}
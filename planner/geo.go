@@ -0,0 +1,55 @@
+package planner
+
+import "math"
+
+// earthRadius is the mean radius of the earth in meters.
+const earthRadius = 6371008.8
+
+// GeoReference places an estate on the globe. Latitude and Longitude are the
+// coordinates of the center of plot (1, 1), Bearing is the direction of the
+// estate rows (the x axis) in degrees clockwise from the true north. The y
+// axis points 90 degrees counterclockwise from the rows, so a bearing of 90
+// lays the rows eastward and the columns northward.
+type GeoReference struct {
+	Latitude  float64
+	Longitude float64
+	Bearing   float64
+}
+
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  int
+}
+
+// Locate converts a waypoint to geographic coordinates. The waypoint is
+// reached by flying from the origin along a great circle, so that estates
+// spanning hundreds of kilometers stay in place on the globe.
+func (ref GeoReference) Locate(waypoint Waypoint) GeoPoint {
+	x, y := float64(waypoint.X), float64(waypoint.Y)
+	// The origin is kept as is, free of rounding errors
+	if x == 0 && y == 0 {
+		return GeoPoint{Latitude: ref.Latitude, Longitude: ref.Longitude, Altitude: waypoint.Altitude}
+	}
+
+	// The y axis points 90 degrees counterclockwise from the rows
+	bearing := radians(ref.Bearing) - math.Atan2(y, x)
+	distance := math.Hypot(x, y) / earthRadius
+	latitude, longitude := radians(ref.Latitude), radians(ref.Longitude)
+
+	toLatitude := math.Asin(math.Sin(latitude)*math.Cos(distance) + math.Cos(latitude)*math.Sin(distance)*math.Cos(bearing))
+	toLongitude := longitude + math.Atan2(math.Sin(bearing)*math.Sin(distance)*math.Cos(latitude), math.Cos(distance)-math.Sin(latitude)*math.Sin(toLatitude))
+
+	// Keep the longitude within -180 to 180 degrees across the antimeridian
+	toLongitude = math.Remainder(toLongitude, 2*math.Pi)
+
+	return GeoPoint{Latitude: degrees(toLatitude), Longitude: degrees(toLongitude), Altitude: waypoint.Altitude}
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
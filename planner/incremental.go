@@ -0,0 +1,62 @@
+package planner
+
+// ZigZagNeighbours returns the plots flown right before and after a plot on
+// the default route. hasPrevious is false on the first plot, and hasNext is
+// false on the last one.
+func ZigZagNeighbours(width, length int, plot Plot) (previous Plot, hasPrevious bool, next Plot, hasNext bool) {
+	index := zigZagIndex(width, plot)
+	if index > 0 {
+		previous, hasPrevious = zigZagPlot(width, index-1), true
+	}
+	if index < width*length-1 {
+		next, hasNext = zigZagPlot(width, index+1), true
+	}
+	return previous, hasPrevious, next, hasNext
+}
+
+// zigZagIndex returns the position of a plot along the default route.
+func zigZagIndex(width int, plot Plot) int {
+	if plot.Y%2 == 0 {
+		return (plot.Y-1)*width + width - plot.X
+	}
+	return (plot.Y-1)*width + plot.X - 1
+}
+
+func zigZagPlot(width, index int) Plot {
+	y := index/width + 1
+	if y%2 == 0 {
+		return Plot{X: width - index%width, Y: y}
+	}
+	return Plot{X: index%width + 1, Y: y}
+}
+
+// AltitudeDelta returns how much the distance of a route changes when the
+// cruise altitude over one of its plots goes from before to after. Only the
+// legs to and from that plot change. previous and next are the cruise
+// altitudes over the plots flown right before and after it, nil at the ends
+// of the route where the drone takes off from or lands on the ground.
+func (c Config) AltitudeDelta(previous, next *int, before, after int) int {
+	return c.verticalLegs(previous, next, after) - c.verticalLegs(previous, next, before)
+}
+
+func (c Config) verticalLegs(previous, next *int, altitude int) (distance int) {
+	switch {
+	case previous != nil:
+		distance += abs(altitude - *previous)
+	case c.TakeoffAltitude > altitude:
+		distance += c.TakeoffAltitude + c.TakeoffAltitude - altitude
+	default:
+		distance += altitude
+	}
+
+	if next != nil {
+		return distance + abs(*next-altitude)
+	}
+	return distance + altitude
+}
+
+// EmptyDistance returns the distance of the default route over an estate
+// without any tree.
+func (c Config) EmptyDistance(width, length int) int {
+	return c.PlotSize*(width*length-1) + c.verticalLegs(nil, nil, c.Clearance)
+}
@@ -0,0 +1,75 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZigZagNeighbours_FollowTheRoute(t *testing.T) {
+	var route []Plot
+	DefaultTraversal().Plots(4, 3, func(plot Plot) bool {
+		route = append(route, plot)
+		return true
+	})
+
+	for i, plot := range route {
+		previous, hasPrevious, next, hasNext := ZigZagNeighbours(4, 3, plot)
+
+		assert.Equal(t, i > 0, hasPrevious, "%v", plot)
+		assert.Equal(t, i < len(route)-1, hasNext, "%v", plot)
+		if hasPrevious {
+			assert.Equal(t, route[i-1], previous)
+		}
+		if hasNext {
+			assert.Equal(t, route[i+1], next)
+		}
+	}
+}
+
+func TestAltitudeDelta_MatchesRecomputation(t *testing.T) {
+	configs := []Config{DefaultConfig(), {PlotSize: 5, Clearance: 3, TakeoffAltitude: 12}}
+	base := []Tree{
+		{X: 1, Y: 1, Height: 4},
+		{X: 3, Y: 1, Height: 7},
+		{X: 2, Y: 2, Height: 15},
+		{X: 4, Y: 3, Height: 2},
+	}
+
+	for _, config := range configs {
+		for _, plot := range []Plot{{1, 2}, {2, 1}, {4, 1}, {4, 2}, {1, 3}, {3, 3}} {
+			assertAltitudeDelta(t, config, base, plot)
+		}
+
+		// Takeoff and landing plots
+		assertAltitudeDelta(t, config, nil, Plot{X: 1, Y: 1})
+		assertAltitudeDelta(t, config, nil, Plot{X: 4, Y: 3})
+	}
+}
+
+func assertAltitudeDelta(t *testing.T, config Config, base []Tree, plot Plot) {
+	before := NewPlanner(NewPlannerOptions{Width: 4, Length: 3, Trees: base, Config: config})
+	planted := append(append([]Tree{}, base...), Tree{X: plot.X, Y: plot.Y, Height: 9})
+	after := NewPlanner(NewPlannerOptions{Width: 4, Length: 3, Trees: planted, Config: config})
+
+	previousPlot, hasPrevious, nextPlot, hasNext := ZigZagNeighbours(4, 3, plot)
+	var previous, next *int
+	if hasPrevious {
+		altitude := before.cruiseAltitude(previousPlot)
+		previous = &altitude
+	}
+	if hasNext {
+		altitude := before.cruiseAltitude(nextPlot)
+		next = &altitude
+	}
+
+	delta := config.AltitudeDelta(previous, next, config.Clearance, 9+config.Clearance)
+	assert.Equal(t, after.Distance(), before.Distance()+delta, "tree on %v with %+v", plot, config)
+}
+
+func TestEmptyDistance(t *testing.T) {
+	for _, config := range []Config{DefaultConfig(), {PlotSize: 5, Clearance: 3, TakeoffAltitude: 12}} {
+		p := NewPlanner(NewPlannerOptions{Width: 7, Length: 4, Config: config})
+		assert.Equal(t, p.Distance(), config.EmptyDistance(7, 4))
+	}
+}
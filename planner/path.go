@@ -0,0 +1,155 @@
+package planner
+
+import "context"
+
+// Waypoint is a point of the flight path. X and Y are in meters from the
+// center of plot (1, 1), Altitude is in meters above the ground.
+type Waypoint struct {
+	X        int `json:"x"`
+	Y        int `json:"y"`
+	Altitude int `json:"altitude"`
+}
+
+// Path calls visit for every waypoint of the flight path, in order, from the
+// takeoff to the landing. The drone climbs before leaving a plot and descends
+// after reaching the next one, so it never flies below the clearance of
+// either plot. Consecutive collinear waypoints are collapsed into one segment.
+// The path stops as soon as visit returns false. With the built-in
+// traversals, the runs of empty plots are flown line by line rather than plot
+// by plot. Path returns the error of ctx when ctx is done before the path.
+func (p *Planner) Path(ctx context.Context, visit func(waypoint Waypoint) bool) error {
+	return p.PathAfter(ctx, -1, func(waypoint Waypoint, cursor int) bool {
+		return visit(waypoint)
+	})
+}
+
+// PathAfter calls visit for the waypoints of Path that come after the one at
+// cursor, along with the cursor of each, to resume the path after it. A
+// negative cursor starts from the takeoff. With the built-in traversals, the
+// path resumes from the plot of the cursor rather than from the takeoff. It
+// returns ErrInvalidCursor when the cursor is past the landing.
+func (p *Planner) PathAfter(ctx context.Context, cursor int, visit func(waypoint Waypoint, cursor int) bool) error {
+	// Every plot has three positions along the path: above the plot flown
+	// before at the altitude to fly over this one, above this one at that
+	// altitude, then at its own altitude. The landing follows the last plot.
+	landing := 3 * p.width * p.length
+	if cursor > landing {
+		return ErrInvalidCursor
+	}
+
+	c := collapser{visit: visit, after: cursor}
+	var current Waypoint
+	at := func(plot Plot, altitude int) Waypoint {
+		return Waypoint{X: (plot.X - 1) * p.config.PlotSize, Y: (plot.Y - 1) * p.config.PlotSize, Altitude: altitude}
+	}
+
+	from := 0
+	if t, ok := p.traversal.(indexed); ok && cursor >= 3 {
+		from = cursor / 3
+		previous := t.plot(p.width, p.length, from-1)
+		current = at(previous, p.cruiseAltitude(previous))
+	}
+
+	err := p.legs(ctx, from, func(l leg) bool {
+		if l.count > 1 {
+			// The run is flown at the altitude the drone is already at
+			for k := 0; k < l.count; {
+				end := p.straight(l, k)
+				c.add(at(p.plotOf(l, k), l.altitude), 3*(l.index+k)+2)
+				c.add(at(p.plotOf(l, end), l.altitude), 3*(l.index+end)+2)
+				k = end + 1
+			}
+			current = at(p.plotOf(l, l.count-1), l.altitude)
+			return !c.stopped
+		}
+
+		position := 3 * l.index
+		if l.index == 0 {
+			c.add(at(l.first, 0), position)
+			c.add(at(l.first, max(p.config.TakeoffAltitude, l.altitude)), position+1)
+		} else {
+			cruise := max(current.Altitude, l.altitude)
+			c.add(Waypoint{X: current.X, Y: current.Y, Altitude: cruise}, position)
+			c.add(at(l.first, cruise), position+1)
+		}
+
+		current = at(l.first, l.altitude)
+		c.add(current, position+2)
+		return !c.stopped
+	})
+	if err != nil {
+		return err
+	}
+
+	current.Altitude = 0
+	c.add(current, landing)
+	c.flush()
+	return nil
+}
+
+// collapser merges consecutive waypoints going in the same direction before
+// handing them to visit, along with their position. The waypoints up to the
+// position after were handed to visit by an earlier path, and are skipped.
+type collapser struct {
+	visit   func(waypoint Waypoint, position int) bool
+	after   int
+	last    Waypoint // Last waypoint handed to visit
+	pending Waypoint // Furthest waypoint reached in the direction from last
+	// position of pending
+	position int
+	count    int
+	stopped  bool
+}
+
+func (c *collapser) add(waypoint Waypoint, position int) {
+	switch {
+	case c.stopped || position < c.after:
+		return
+	case position == c.after:
+		// Resume as if the waypoint had just been handed to visit
+		c.last = waypoint
+		c.count = 1
+	case c.count == 0:
+		c.last = waypoint
+		c.count++
+		c.emit(waypoint, position)
+	case c.count == 1:
+		if waypoint != c.last {
+			c.pending, c.position = waypoint, position
+			c.count++
+		}
+	case waypoint == c.pending:
+		return
+	case direction(c.last, c.pending) == direction(c.pending, waypoint):
+		c.pending, c.position = waypoint, position
+	default:
+		c.emit(c.pending, c.position)
+		c.last, c.pending, c.position = c.pending, waypoint, position
+	}
+}
+
+func (c *collapser) flush() {
+	if c.count > 1 {
+		c.emit(c.pending, c.position)
+	}
+}
+
+func (c *collapser) emit(waypoint Waypoint, position int) {
+	if !c.stopped && !c.visit(waypoint, position) {
+		c.stopped = true
+	}
+}
+
+func direction(from, to Waypoint) [3]int {
+	return [3]int{sign(to.X - from.X), sign(to.Y - from.Y), sign(to.Altitude - from.Altitude)}
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,128 @@
+package planner
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectPath(width, length int, trees []Tree) (waypoints []Waypoint) {
+	newTestPlanner(width, length, trees).Path(context.Background(), func(waypoint Waypoint) bool {
+		waypoints = append(waypoints, waypoint)
+		return true
+	})
+	return waypoints
+}
+
+func TestPath_CollapsesCollinearWaypoints(t *testing.T) {
+	trees := []Tree{
+		{X: 1, Y: 2, Height: 10},
+		{X: 1, Y: 3, Height: 20},
+		{X: 1, Y: 4, Height: 10},
+	}
+
+	assert.Equal(t, []Waypoint{
+		{X: 0, Y: 0, Altitude: 0},
+		{X: 0, Y: 0, Altitude: 11},
+		{X: 0, Y: 10, Altitude: 11},
+		{X: 0, Y: 10, Altitude: 21},
+		{X: 0, Y: 30, Altitude: 21},
+		{X: 0, Y: 30, Altitude: 11},
+		{X: 0, Y: 40, Altitude: 11},
+		{X: 0, Y: 40, Altitude: 0},
+	}, collectPath(1, 5, trees))
+}
+
+func TestPath_LengthMatchesDistance(t *testing.T) {
+	trees := []Tree{
+		{X: 2, Y: 1, Height: 3},
+		{X: 3, Y: 2, Height: 5},
+		{X: 1, Y: 3, Height: 12},
+	}
+
+	waypoints := collectPath(4, 3, trees)
+
+	length := 0
+	for i := 1; i < len(waypoints); i++ {
+		length += abs(waypoints[i].X-waypoints[i-1].X) + abs(waypoints[i].Y-waypoints[i-1].Y) + abs(waypoints[i].Altitude-waypoints[i-1].Altitude)
+	}
+	assert.Equal(t, newTestPlanner(4, 3, trees).Distance(), length)
+}
+
+func TestPath_StopsWhenVisitReturnsFalse(t *testing.T) {
+	var waypoints []Waypoint
+	newTestPlanner(10, 10, nil).Path(context.Background(), func(waypoint Waypoint) bool {
+		waypoints = append(waypoints, waypoint)
+		return len(waypoints) < 3
+	})
+
+	assert.Len(t, waypoints, 3)
+}
+
+func TestPath_CustomConfig(t *testing.T) {
+	p := NewPlanner(NewPlannerOptions{
+		Width:  2,
+		Length: 2,
+		Trees:  []Tree{{X: 2, Y: 2, Height: 4}},
+		Config: Config{PlotSize: 5, Clearance: 3, TakeoffAltitude: 10},
+	})
+
+	var waypoints []Waypoint
+	p.Path(context.Background(), func(waypoint Waypoint) bool {
+		waypoints = append(waypoints, waypoint)
+		return true
+	})
+
+	assert.Equal(t, []Waypoint{
+		{X: 0, Y: 0, Altitude: 0},
+		{X: 0, Y: 0, Altitude: 10},
+		{X: 0, Y: 0, Altitude: 3},
+		{X: 5, Y: 0, Altitude: 3},
+		{X: 5, Y: 0, Altitude: 7},
+		{X: 5, Y: 5, Altitude: 7},
+		{X: 0, Y: 5, Altitude: 7},
+		{X: 0, Y: 5, Altitude: 0},
+	}, waypoints)
+}
+
+func TestPathAfter_ResumesAfterEveryCursor(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	configs := []Config{DefaultConfig(), {PlotSize: 5, Clearance: 3, TakeoffAltitude: 20}}
+
+	for _, pattern := range Patterns {
+		traversal, _ := NewTraversal(pattern, CornerNorthEast)
+		for _, config := range configs {
+			for _, size := range [][2]int{{1, 1}, {1, 7}, {7, 5}, {12, 9}} {
+				trees := randomTrees(r, size[0], size[1], r.Intn(size[0]*size[1]/2+1))
+				for _, traversal := range []Traversal{traversal, walked{traversal}} {
+					p := NewPlanner(NewPlannerOptions{Width: size[0], Length: size[1], Trees: trees, Config: config, Traversal: traversal})
+
+					var waypoints []Waypoint
+					var cursors []int
+					assert.NoError(t, p.PathAfter(context.Background(), -1, func(waypoint Waypoint, cursor int) bool {
+						waypoints = append(waypoints, waypoint)
+						cursors = append(cursors, cursor)
+						return true
+					}))
+
+					for i, cursor := range cursors {
+						var rest []Waypoint
+						assert.NoError(t, p.PathAfter(context.Background(), cursor, func(waypoint Waypoint, cursor int) bool {
+							rest = append(rest, waypoint)
+							return true
+						}))
+						assert.Equal(t, waypoints[i+1:], append([]Waypoint{}, rest...), "%s on %v after %d", pattern, size, cursor)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPathAfter_CursorPastLanding(t *testing.T) {
+	err := newTestPlanner(3, 2, nil).PathAfter(context.Background(), 19, func(Waypoint, int) bool { return true })
+
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
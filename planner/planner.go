@@ -0,0 +1,287 @@
+// Package planner computes the route the monitoring drone flies over an
+// estate. By default the drone covers every plot in a zig-zag pattern: it
+// starts at plot (1, 1), flies east along the first row, turns north and
+// flies west along the second row, and so on until every plot has been
+// visited. Other patterns are provided as a Traversal.
+package planner
+
+import "context"
+
+const (
+	// DefaultPlotSize is the horizontal distance in meters between two
+	// adjacent plots.
+	DefaultPlotSize = 10
+	// DefaultClearance is how far in meters the drone flies above a tree (or
+	// above the ground when the plot is empty).
+	DefaultClearance = 1
+)
+
+type Tree struct {
+	X      int
+	Y      int
+	Height int
+}
+
+type Plot struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Config holds the flight parameters of an estate, in meters.
+type Config struct {
+	PlotSize  int
+	Clearance int
+	// TakeoffAltitude is the altitude the drone climbs to before heading to
+	// the first plot. It has no effect when lower than the cruise altitude
+	// over the first plot.
+	TakeoffAltitude int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PlotSize:  DefaultPlotSize,
+		Clearance: DefaultClearance,
+	}
+}
+
+// Sortie is a single flight of a mission, between a takeoff and a landing.
+// The first sortie takes off from the first plot of the route, the following
+// ones resume from the plot the previous sortie landed on, which is not
+// covered again.
+type Sortie struct {
+	Start    Plot `json:"start"`
+	End      Plot `json:"end"`
+	Distance int  `json:"distance"`
+	Plots    int  `json:"plots"`
+}
+
+type Planner struct {
+	width     int
+	length    int
+	heights   map[Plot]int
+	config    Config
+	traversal Traversal
+}
+
+type NewPlannerOptions struct {
+	Width  int
+	Length int
+	Trees  []Tree
+	Config Config
+	// Traversal defaults to DefaultTraversal when nil
+	Traversal Traversal
+}
+
+func NewPlanner(opts NewPlannerOptions) *Planner {
+	heights := make(map[Plot]int, len(opts.Trees))
+	for _, tree := range opts.Trees {
+		heights[Plot{X: tree.X, Y: tree.Y}] = tree.Height
+	}
+
+	traversal := opts.Traversal
+	if traversal == nil {
+		traversal = DefaultTraversal()
+	}
+
+	return &Planner{
+		width:     opts.Width,
+		length:    opts.Length,
+		heights:   heights,
+		config:    opts.Config,
+		traversal: traversal,
+	}
+}
+
+// Distance returns the total distance in meters the drone travels to cover
+// the estate, including the takeoff from and the landing to the ground. With
+// the built-in traversals it takes time proportional to the number of trees,
+// whatever the size of the estate.
+func (p *Planner) Distance() int {
+	if t, ok := p.traversal.(indexed); ok {
+		return p.sparseDistance(t)
+	}
+	return p.walkDistance()
+}
+
+// walkDistance computes Distance by flying over every plot.
+func (p *Planner) walkDistance() int {
+	total := 0
+	p.walk(context.Background(), func(plot Plot, altitude, distance int) bool {
+		// Land back on the ground at the last plot
+		total = distance + altitude
+		return true
+	})
+	return total
+}
+
+// SmoothDistance returns the shortest distance the drone can travel to cover
+// the estate without ever flying below the cruise altitude of a plot. Instead
+// of returning to the cruise altitude over every plot, the drone holds its
+// altitude whenever a taller plot is still ahead: it climbs steadily up to
+// the highest cruise altitude of the route and descends steadily after it,
+// so the vertical distance is twice the highest altitude.
+func (p *Planner) SmoothDistance() int {
+	if _, ok := p.traversal.(indexed); ok {
+		return p.sparseSmoothDistance()
+	}
+
+	horizontal, peak := 0, p.config.TakeoffAltitude
+	first := true
+	var previous Plot
+
+	p.traversal.Plots(p.width, p.length, func(plot Plot) bool {
+		if !first {
+			horizontal += p.config.PlotSize * (abs(plot.X-previous.X) + abs(plot.Y-previous.Y))
+		}
+		if altitude := p.cruiseAltitude(plot); altitude > peak {
+			peak = altitude
+		}
+		first = false
+		previous = plot
+		return true
+	})
+
+	return horizontal + 2*peak
+}
+
+// DistanceWithLimit walks the route with a battery that lasts maxDistance
+// meters. The drone lands at the last plot it can reach while keeping enough
+// battery to descend to the ground there. It returns the distance flown,
+// landing included, and the plot where the drone rests. With the built-in
+// traversals it takes time proportional to the number of trees. It returns
+// the error of ctx when ctx is done before the walk.
+//
+// When the battery does not even allow to take off and land on the first
+// plot, the drone stays on the ground there.
+func (p *Planner) DistanceWithLimit(ctx context.Context, maxDistance int) (flown int, rest Plot, err error) {
+	rest = p.start()
+	distance := 0
+
+	err = p.legs(ctx, 0, func(l leg) bool {
+		// The distance flown to a plot and down to the ground there only
+		// grows along the route
+		reached := l.fit(maxDistance - distance)
+		if reached > 0 {
+			flown, rest = distance+reached*l.hop+l.altitude, p.plotOf(l, reached-1)
+		}
+		distance += l.count * l.hop
+		return reached == l.count
+	})
+	if err != nil {
+		return 0, Plot{}, err
+	}
+	return flown, rest, nil
+}
+
+// Missions splits the route in sorties that each fit in a battery lasting
+// batteryCapacity meters, landing included. The drone recharges where it
+// landed and takes off again from there. It returns ErrBatteryTooSmall when a
+// sortie cannot cover even one plot, ErrTooManySorties when the route takes
+// more than maxSorties sorties, and the error of ctx when ctx is done before
+// the route is split.
+func (p *Planner) Missions(ctx context.Context, batteryCapacity, maxSorties int) (sorties []Sortie, err error) {
+	sortie := Sortie{Start: p.start()}
+	flown, altitude := 0, 0
+
+	walkErr := p.legs(ctx, 0, func(l leg) bool {
+		for covered := 0; covered < l.count; {
+			reached := min(l.fit(batteryCapacity-flown), l.count-covered)
+			if reached > 0 {
+				flown += reached * l.hop
+				altitude = l.altitude
+				covered += reached
+				sortie.End = p.plotOf(l, covered-1)
+				sortie.Plots += reached
+				continue
+			}
+
+			if sortie.Plots == 0 {
+				err = ErrBatteryTooSmall
+				return false
+			}
+
+			// Land on the last covered plot, recharge and take off again
+			sortie.Distance = flown + altitude
+			sorties = append(sorties, sortie)
+			if len(sorties) >= maxSorties {
+				err = ErrTooManySorties
+				return false
+			}
+			if len(sorties)%checkEvery == 0 && ctx.Err() != nil {
+				return false
+			}
+
+			sortie = Sortie{Start: sortie.End}
+			flown = altitude
+		}
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sortie.Distance = flown + altitude
+	return append(sorties, sortie), nil
+}
+
+// cruiseAltitude returns the altitude the drone flies at above a plot.
+func (p *Planner) cruiseAltitude(plot Plot) int {
+	return p.heights[plot] + p.config.Clearance
+}
+
+// start returns the plot the drone takes off from.
+func (p *Planner) start() (first Plot) {
+	p.traversal.Plots(p.width, p.length, func(plot Plot) bool {
+		first = plot
+		return false
+	})
+	return first
+}
+
+// walk follows the route and calls visit for every plot with the altitude
+// the drone flies at above it and the distance flown since takeoff to get
+// there. The walk stops as soon as visit returns false, and returns the
+// error of ctx when ctx is done before the walk.
+func (p *Planner) walk(ctx context.Context, visit func(plot Plot, altitude, distance int) bool) error {
+	visited := 0
+	distance := 0
+	altitude := 0 // The drone takes off from the ground
+	var previous Plot
+	first := true
+
+	p.traversal.Plots(p.width, p.length, func(plot Plot) bool {
+		visited++
+		if visited%checkEvery == 0 && ctx.Err() != nil {
+			return false
+		}
+
+		target := p.cruiseAltitude(plot)
+		if first {
+			// Climb to the takeoff altitude before settling on the first plot
+			if p.config.TakeoffAltitude > target {
+				distance += p.config.TakeoffAltitude
+				altitude = p.config.TakeoffAltitude
+			}
+			first = false
+		} else {
+			distance += p.config.PlotSize * (abs(plot.X-previous.X) + abs(plot.Y-previous.Y))
+		}
+
+		distance += abs(target - altitude)
+		altitude = target
+		previous = plot
+
+		return visit(plot, altitude, distance)
+	})
+	return ctx.Err()
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
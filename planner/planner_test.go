@@ -0,0 +1,187 @@
+package planner
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPlanner(width, length int, trees []Tree) *Planner {
+	return NewPlanner(NewPlannerOptions{Width: width, Length: length, Trees: trees, Config: DefaultConfig()})
+}
+
+func TestDistance_EmptyEstate(t *testing.T) {
+	// 5 plots in a single row: 4 hops of 10m, plus takeoff and landing of 1m
+	assert.Equal(t, 42, newTestPlanner(5, 1, nil).Distance())
+}
+
+func TestDistance_SingleColumn(t *testing.T) {
+	trees := []Tree{
+		{X: 1, Y: 2, Height: 10},
+		{X: 1, Y: 3, Height: 20},
+		{X: 1, Y: 4, Height: 10},
+	}
+
+	assert.Equal(t, 82, newTestPlanner(1, 5, trees).Distance())
+}
+
+func TestDistance_ZigZag(t *testing.T) {
+	// Route: (1,1) (2,1) (3,1) (3,2) (2,2) (1,2)
+	// The tree at (3,2) is visited right after (3,1) when turning north
+	trees := []Tree{
+		{X: 3, Y: 2, Height: 5},
+	}
+
+	assert.Equal(t, 50+1+5+5+1, newTestPlanner(3, 2, trees).Distance())
+}
+
+func TestDistanceWithLimit_RunsOutOfBattery(t *testing.T) {
+	trees := []Tree{
+		{X: 1, Y: 2, Height: 10},
+		{X: 1, Y: 3, Height: 20},
+		{X: 1, Y: 4, Height: 10},
+	}
+
+	// Reaching (1,3) costs 1+10+10+10+10 = 41m and landing there another 21m,
+	// so a 60m battery has to land at (1,2): 1+10+10 = 21m plus 11m down
+	flown, rest, err := newTestPlanner(1, 5, trees).DistanceWithLimit(context.Background(), 60)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 32, flown)
+	assert.Equal(t, Plot{X: 1, Y: 2}, rest)
+}
+
+func TestDistanceWithLimit_EnoughBattery(t *testing.T) {
+	flown, rest, err := newTestPlanner(3, 2, nil).DistanceWithLimit(context.Background(), 1000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, newTestPlanner(3, 2, nil).Distance(), flown)
+	assert.Equal(t, Plot{X: 1, Y: 2}, rest)
+}
+
+func TestDistanceWithLimit_CannotTakeOff(t *testing.T) {
+	trees := []Tree{
+		{X: 1, Y: 1, Height: 10},
+	}
+
+	flown, rest, err := newTestPlanner(3, 2, trees).DistanceWithLimit(context.Background(), 5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, flown)
+	assert.Equal(t, Plot{X: 1, Y: 1}, rest)
+}
+
+func TestMissions_SingleSortie(t *testing.T) {
+	sorties, err := newTestPlanner(3, 2, nil).Missions(context.Background(), 1000, math.MaxInt)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Sortie{
+		{Start: Plot{X: 1, Y: 1}, End: Plot{X: 1, Y: 2}, Distance: newTestPlanner(3, 2, nil).Distance(), Plots: 6},
+	}, sorties)
+}
+
+func TestMissions_ResumesWhereLanded(t *testing.T) {
+	trees := []Tree{
+		{X: 1, Y: 2, Height: 10},
+		{X: 1, Y: 3, Height: 20},
+		{X: 1, Y: 4, Height: 10},
+	}
+
+	sorties, err := newTestPlanner(1, 5, trees).Missions(context.Background(), 60, math.MaxInt)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Sortie{
+		// 1 up, 10+10 to (1,2), 11 down
+		{Start: Plot{X: 1, Y: 1}, End: Plot{X: 1, Y: 2}, Distance: 32, Plots: 2},
+		// 11 up, 10+10 to (1,3), 21 down: going on to (1,4) would need 72m
+		{Start: Plot{X: 1, Y: 2}, End: Plot{X: 1, Y: 3}, Distance: 52, Plots: 1},
+		// 21 up, 10+10 to (1,4), 11 down
+		{Start: Plot{X: 1, Y: 3}, End: Plot{X: 1, Y: 4}, Distance: 52, Plots: 1},
+		// 11 up, 10+10 to (1,5), 1 down
+		{Start: Plot{X: 1, Y: 4}, End: Plot{X: 1, Y: 5}, Distance: 32, Plots: 1},
+	}, sorties)
+}
+
+func TestMissions_BatteryTooSmall(t *testing.T) {
+	_, err := newTestPlanner(3, 2, nil).Missions(context.Background(), 1, math.MaxInt)
+
+	assert.ErrorIs(t, err, ErrBatteryTooSmall)
+}
+
+func TestMissions_TooManySorties(t *testing.T) {
+	trees := []Tree{
+		{X: 1, Y: 2, Height: 10},
+		{X: 1, Y: 3, Height: 20},
+		{X: 1, Y: 4, Height: 10},
+	}
+
+	// The route takes 4 sorties, see TestMissions_ResumesWhereLanded
+	_, err := newTestPlanner(1, 5, trees).Missions(context.Background(), 60, 3)
+	assert.ErrorIs(t, err, ErrTooManySorties)
+
+	sorties, err := newTestPlanner(1, 5, trees).Missions(context.Background(), 60, 4)
+	assert.NoError(t, err)
+	assert.Len(t, sorties, 4)
+}
+
+func TestDistance_CustomConfig(t *testing.T) {
+	p := NewPlanner(NewPlannerOptions{
+		Width:  5,
+		Length: 1,
+		Trees:  []Tree{{X: 3, Y: 1, Height: 4}},
+		Config: Config{PlotSize: 5, Clearance: 3, TakeoffAltitude: 10},
+	})
+
+	// Up to 10m, down to 3m, 4 hops of 5m, up and down 4m over the tree, 3m landing
+	assert.Equal(t, 10+7+20+8+3, p.Distance())
+}
+
+func TestDistance_TakeoffBelowCruiseAltitude(t *testing.T) {
+	p := NewPlanner(NewPlannerOptions{
+		Width:  5,
+		Length: 1,
+		Config: Config{PlotSize: 10, Clearance: 3, TakeoffAltitude: 2},
+	})
+
+	assert.Equal(t, 3+40+3, p.Distance())
+}
+
+func TestSmoothDistance_HoldsAltitudeBetweenTallTrees(t *testing.T) {
+	trees := []Tree{
+		{X: 1, Y: 1, Height: 10},
+		{X: 3, Y: 1, Height: 20},
+	}
+	p := newTestPlanner(5, 1, trees)
+
+	// Naive: up 11, down 10, up 20, down 20, landing 1
+	assert.Equal(t, 40+11+10+20+20+1, p.Distance())
+	// Smooth: up 21 over the first plots, hold, down 21 after the tallest tree
+	assert.Equal(t, 40+21+21, p.SmoothDistance())
+}
+
+func TestSmoothDistance_NeverLongerThanNaive(t *testing.T) {
+	trees := []Tree{
+		{X: 2, Y: 1, Height: 3},
+		{X: 3, Y: 2, Height: 5},
+		{X: 1, Y: 3, Height: 12},
+	}
+
+	for _, pattern := range Patterns {
+		traversal, _ := NewTraversal(pattern, CornerSouthWest)
+		p := NewPlanner(NewPlannerOptions{Width: 4, Length: 3, Trees: trees, Config: DefaultConfig(), Traversal: traversal})
+		assert.LessOrEqual(t, p.SmoothDistance(), p.Distance())
+	}
+}
+
+func TestSmoothDistance_TakeoffAltitude(t *testing.T) {
+	p := NewPlanner(NewPlannerOptions{
+		Width:  5,
+		Length: 1,
+		Config: Config{PlotSize: 10, Clearance: 1, TakeoffAltitude: 15},
+	})
+
+	assert.Equal(t, p.Distance(), p.SmoothDistance())
+	assert.Equal(t, 40+15+15, p.SmoothDistance())
+}
@@ -0,0 +1,306 @@
+package planner
+
+import (
+	"context"
+	"sort"
+)
+
+// indexed is implemented by traversals that only fly between adjacent plots
+// and can tell the position of a plot along the route without walking it.
+// The planner relies on it to compute distances in time proportional to the
+// number of trees rather than to the number of plots: every hop is one plot
+// long, and the drone only leaves the clearance altitude around trees.
+type indexed interface {
+	index(width, length int, plot Plot) int
+	// plot is the inverse of index
+	plot(width, length, index int) Plot
+}
+
+func (b boustrophedon) index(width, length int, plot Plot) int {
+	plot = b.mirror.apply(width, length, plot)
+	line, step, span := plot.Y, plot.X, width
+	if b.columns {
+		line, step, span = plot.X, plot.Y, length
+	}
+
+	if line%2 == 0 {
+		return (line-1)*span + span - step
+	}
+	return (line-1)*span + step - 1
+}
+
+func (b boustrophedon) plot(width, length, index int) Plot {
+	span := width
+	if b.columns {
+		span = length
+	}
+
+	line, step := index/span+1, index%span+1
+	if line%2 == 0 {
+		step = span + 1 - step
+	}
+
+	plot := Plot{X: step, Y: line}
+	if b.columns {
+		plot = Plot{X: line, Y: step}
+	}
+	return b.mirror.apply(width, length, plot)
+}
+
+func (s spiral) index(width, length int, plot Plot) int {
+	plot = s.mirror.apply(width, length, plot)
+
+	// The rings outside the one holding the plot are flown first
+	ring := min(plot.X-1, plot.Y-1, width-plot.X, length-plot.Y)
+	w, l := width-2*ring, length-2*ring
+	x, y := plot.X-ring, plot.Y-ring
+	index := width*length - w*l
+
+	switch {
+	case y == 1:
+		return index + x - 1
+	case x == w:
+		return index + w - 1 + y - 1
+	case y == l:
+		return index + w - 1 + l - 1 + w - x
+	}
+	return index + 2*(w-1) + l - 1 + l - y
+}
+
+func (s spiral) plot(width, length, index int) Plot {
+	// Find the ring holding the plot from the number of plots flown before
+	// each ring
+	before := func(ring int) int {
+		return width*length - (width-2*ring)*(length-2*ring)
+	}
+	ring := sort.Search((min(width, length)+1)/2, func(ring int) bool {
+		return before(ring) > index
+	}) - 1
+
+	w, l := width-2*ring, length-2*ring
+	k := index - before(ring)
+	var x, y int
+	switch {
+	case k < w:
+		x, y = k+1, 1
+	case k < w+l-1:
+		x, y = w, k-w+2
+	case k < 2*w+l-2:
+		x, y = 2*w+l-2-k, l
+	default:
+		x, y = 1, 2*w+2*l-3-k
+	}
+
+	return s.mirror.apply(width, length, Plot{X: x + ring, Y: y + ring})
+}
+
+// sparseDistance computes Distance from the trees only. It starts from the
+// route over the empty estate and plants the trees in flight order, so that
+// the plot flown after each of them is still empty when it is planted.
+func (p *Planner) sparseDistance(t indexed) int {
+	type stop struct {
+		index    int
+		altitude int
+	}
+
+	stops := make([]stop, 0, len(p.heights))
+	for plot, height := range p.heights {
+		stops = append(stops, stop{
+			index:    t.index(p.width, p.length, plot),
+			altitude: height + p.config.Clearance,
+		})
+	}
+	sort.Slice(stops, func(i, j int) bool {
+		return stops[i].index < stops[j].index
+	})
+
+	clearance := p.config.Clearance
+	last := p.width*p.length - 1
+	distance := p.config.EmptyDistance(p.width, p.length)
+
+	for i, s := range stops {
+		var previous, next *int
+		if s.index > 0 {
+			previous = &clearance
+			if i > 0 && stops[i-1].index == s.index-1 {
+				previous = &stops[i-1].altitude
+			}
+		}
+		if s.index < last {
+			next = &clearance
+		}
+
+		distance += p.config.AltitudeDelta(previous, next, clearance, s.altitude)
+	}
+
+	return distance
+}
+
+// sparseSmoothDistance computes SmoothDistance from the trees only.
+func (p *Planner) sparseSmoothDistance() int {
+	peak := max(p.config.TakeoffAltitude, p.config.Clearance)
+	for _, height := range p.heights {
+		peak = max(peak, height+p.config.Clearance)
+	}
+
+	return p.config.PlotSize*(p.width*p.length-1) + 2*peak
+}
+
+// leg is a stretch of count consecutive plots of the route, starting at the
+// plot flown at index, all flown at the same altitude and each reached after
+// flying hop meters, climbs and descents included.
+type leg struct {
+	first    Plot
+	index    int
+	count    int
+	hop      int
+	altitude int
+}
+
+// fit returns how many plots of the leg the drone reaches with budget meters
+// of battery left, keeping enough to land on the last of them.
+func (l leg) fit(budget int) int {
+	budget -= l.altitude
+	switch {
+	case budget < 0:
+		return 0
+	case l.hop == 0 || budget/l.hop >= l.count:
+		return l.count
+	}
+	return budget / l.hop
+}
+
+// checkEvery is how many plots or legs go by between two checks of the
+// context of a walk.
+const checkEvery = 1 << 16
+
+// legs follows the route from the plot flown at index from and calls visit
+// for every leg, in order, until visit returns false. With an indexed
+// traversal, a tree and the plot flown after it are legs of their own, and
+// the runs of empty plots in between are legs as long as the run, so that
+// the number of legs is proportional to the number of trees. Other
+// traversals get a leg per plot, and are walked from the first plot even
+// when they start later. legs returns the error of ctx when it is done
+// before the walk.
+func (p *Planner) legs(ctx context.Context, from int, visit func(l leg) bool) error {
+	t, ok := p.traversal.(indexed)
+	if !ok {
+		index, previous := 0, 0
+		return p.walk(ctx, func(plot Plot, altitude, distance int) bool {
+			l := leg{first: plot, index: index, count: 1, hop: distance - previous, altitude: altitude}
+			index, previous = index+1, distance
+			return l.index < from || visit(l)
+		})
+	}
+
+	type stop struct {
+		index    int
+		altitude int
+	}
+	stops := make([]stop, 0, len(p.heights))
+	for plot, height := range p.heights {
+		stops = append(stops, stop{index: t.index(p.width, p.length, plot), altitude: height + p.config.Clearance})
+	}
+	sort.Slice(stops, func(i, j int) bool {
+		return stops[i].index < stops[j].index
+	})
+
+	clearance := p.config.Clearance
+	plots := p.width * p.length
+	visited := 0
+	fly := func(l leg) bool {
+		visited++
+		if visited%checkEvery == 0 && ctx.Err() != nil {
+			return false
+		}
+		if l.count == 1 {
+			l.first = t.plot(p.width, p.length, l.index)
+		}
+		return visit(l)
+	}
+
+	// Skip the trees flown before, but the one right before from
+	skipped := sort.Search(len(stops), func(i int) bool {
+		return stops[i].index >= from-1
+	})
+	stops = stops[skipped:]
+
+	altitude := clearance
+	if len(stops) > 0 && stops[0].index == from-1 {
+		altitude = stops[0].altitude
+		stops = stops[1:]
+	}
+
+	next := from
+	if from == 0 {
+		// The drone takes off to the first plot
+		if len(stops) > 0 && stops[0].index == 0 {
+			altitude = stops[0].altitude
+			stops = stops[1:]
+		}
+		hop := altitude
+		if p.config.TakeoffAltitude > altitude {
+			hop = 2*p.config.TakeoffAltitude - altitude
+		}
+		if !fly(leg{count: 1, hop: hop, altitude: altitude}) {
+			return ctx.Err()
+		}
+		next = 1
+	}
+
+	// empty flies over the empty plots from next up to the one before to
+	empty := func(to int) bool {
+		if next < to && altitude != clearance {
+			if !fly(leg{index: next, count: 1, hop: p.config.PlotSize + abs(clearance-altitude), altitude: clearance}) {
+				return false
+			}
+			altitude = clearance
+			next++
+		}
+		if next < to {
+			l := leg{first: t.plot(p.width, p.length, next), index: next, count: to - next, hop: p.config.PlotSize, altitude: clearance}
+			if !fly(l) {
+				return false
+			}
+			next = to
+		}
+		return true
+	}
+
+	for _, s := range stops {
+		if !empty(s.index) {
+			return ctx.Err()
+		}
+		if !fly(leg{index: s.index, count: 1, hop: p.config.PlotSize + abs(s.altitude-altitude), altitude: s.altitude}) {
+			return ctx.Err()
+		}
+		altitude = s.altitude
+		next = s.index + 1
+	}
+	empty(plots)
+	return ctx.Err()
+}
+
+// plotOf returns the k-th plot of a leg.
+func (p *Planner) plotOf(l leg, k int) Plot {
+	if k == 0 {
+		return l.first
+	}
+	return p.traversal.(indexed).plot(p.width, p.length, l.index+k)
+}
+
+// straight returns the last plot of a leg, from the k-th one on, that the
+// drone reaches without turning. The built-in traversals never come back to
+// a line they turned away from, so the plots in line are searched for.
+func (p *Planner) straight(l leg, k int) int {
+	if k+1 >= l.count {
+		return k
+	}
+	from, to := p.plotOf(l, k), p.plotOf(l, k+1)
+	dx, dy := to.X-from.X, to.Y-from.Y
+
+	return k + sort.Search(l.count-k, func(n int) bool {
+		plot := p.plotOf(l, k+n)
+		return plot != Plot{X: from.X + n*dx, Y: from.Y + n*dy}
+	}) - 1
+}
@@ -0,0 +1,170 @@
+package planner
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// walked hides the index of a traversal, so the planner flies over every plot.
+type walked struct {
+	Traversal
+}
+
+func randomTrees(r *rand.Rand, width, length, count int) []Tree {
+	trees := make([]Tree, 0, count)
+	planted := make(map[Plot]bool, count)
+	for len(trees) < count {
+		plot := Plot{X: r.Intn(width) + 1, Y: r.Intn(length) + 1}
+		if planted[plot] {
+			continue
+		}
+		planted[plot] = true
+		trees = append(trees, Tree{X: plot.X, Y: plot.Y, Height: r.Intn(30) + 1})
+	}
+	return trees
+}
+
+func TestIndex_FollowsTheRoute(t *testing.T) {
+	for _, pattern := range Patterns {
+		for _, corner := range Corners {
+			traversal, _ := NewTraversal(pattern, corner)
+			for _, size := range [][2]int{{1, 1}, {1, 4}, {4, 1}, {3, 3}, {4, 5}, {6, 3}} {
+				for i, plot := range collectPlots(traversal, size[0], size[1]) {
+					assert.Equal(t, i, traversal.(indexed).index(size[0], size[1], plot), "%s from %s on %v at %v", pattern, corner, size, plot)
+					assert.Equal(t, plot, traversal.(indexed).plot(size[0], size[1], i), "%s from %s on %v at %d", pattern, corner, size, i)
+				}
+			}
+		}
+	}
+}
+
+func TestSparseDistance_MatchesWalk(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	configs := []Config{DefaultConfig(), {PlotSize: 5, Clearance: 3, TakeoffAltitude: 20}}
+
+	for _, pattern := range Patterns {
+		for _, corner := range Corners {
+			traversal, _ := NewTraversal(pattern, corner)
+			for _, config := range configs {
+				for _, size := range [][2]int{{1, 1}, {1, 7}, {7, 1}, {5, 5}, {8, 3}, {12, 9}} {
+					width, length := size[0], size[1]
+					trees := randomTrees(r, width, length, r.Intn(width*length+1))
+
+					opts := NewPlannerOptions{Width: width, Length: length, Trees: trees, Config: config, Traversal: traversal}
+					sparse := NewPlanner(opts)
+					opts.Traversal = walked{traversal}
+					walk := NewPlanner(opts)
+
+					assert.Equal(t, walk.Distance(), sparse.Distance(), "%s from %s on %v with %d trees", pattern, corner, size, len(trees))
+					assert.Equal(t, walk.SmoothDistance(), sparse.SmoothDistance(), "%s from %s on %v with %d trees", pattern, corner, size, len(trees))
+					assert.Equal(t, collectWaypoints(t, walk), collectWaypoints(t, sparse), "%s from %s on %v with %d trees", pattern, corner, size, len(trees))
+
+					for _, battery := range []int{1, 40, 75, 200, 1000} {
+						walkFlown, walkRest, walkErr := walk.DistanceWithLimit(context.Background(), battery)
+						sparseFlown, sparseRest, sparseErr := sparse.DistanceWithLimit(context.Background(), battery)
+						assert.NoError(t, sparseErr)
+						assert.Equal(t, []any{walkFlown, walkRest, walkErr}, []any{sparseFlown, sparseRest, sparseErr}, "%s from %s on %v with %d trees and %dm", pattern, corner, size, len(trees), battery)
+
+						walkSorties, walkErr := walk.Missions(context.Background(), battery, math.MaxInt)
+						sparseSorties, sparseErr := sparse.Missions(context.Background(), battery, math.MaxInt)
+						assert.Equal(t, walkErr, sparseErr, "%s from %s on %v with %d trees and %dm", pattern, corner, size, len(trees), battery)
+						assert.Equal(t, walkSorties, sparseSorties, "%s from %s on %v with %d trees and %dm", pattern, corner, size, len(trees), battery)
+					}
+				}
+			}
+		}
+	}
+}
+
+func collectWaypoints(t *testing.T, p *Planner) (waypoints []Waypoint) {
+	assert.NoError(t, p.Path(context.Background(), func(waypoint Waypoint) bool {
+		waypoints = append(waypoints, waypoint)
+		return true
+	}))
+	return waypoints
+}
+
+func TestSparse_StopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, traversal := range []Traversal{DefaultTraversal(), walked{DefaultTraversal()}} {
+		p := NewPlanner(NewPlannerOptions{Width: 1000, Length: 1000, Trees: randomTrees(rand.New(rand.NewSource(1)), 1000, 1000, 100000), Config: DefaultConfig(), Traversal: traversal})
+
+		_, _, err := p.DistanceWithLimit(ctx, math.MaxInt)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		_, err = p.Missions(ctx, 100, math.MaxInt)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		assert.ErrorIs(t, p.Path(ctx, func(Waypoint) bool { return true }), context.Canceled)
+	}
+}
+
+// The largest estate the API accepts, with 100k trees
+func BenchmarkDistance_LargestEstate(b *testing.B) {
+	trees := randomTrees(rand.New(rand.NewSource(1)), 50000, 50000, 100000)
+
+	for _, pattern := range Patterns {
+		b.Run(pattern, func(b *testing.B) {
+			traversal, _ := NewTraversal(pattern, CornerSouthWest)
+			for i := 0; i < b.N; i++ {
+				NewPlanner(NewPlannerOptions{Width: 50000, Length: 50000, Trees: trees, Config: DefaultConfig(), Traversal: traversal}).Distance()
+			}
+		})
+	}
+}
+
+func BenchmarkComparePatterns_LargestEstate(b *testing.B) {
+	trees := randomTrees(rand.New(rand.NewSource(1)), 50000, 50000, 100000)
+
+	for i := 0; i < b.N; i++ {
+		ComparePatterns(NewPlannerOptions{Width: 50000, Length: 50000, Trees: trees, Config: DefaultConfig()})
+	}
+}
+
+func BenchmarkDistanceWithLimit_LargestEstate(b *testing.B) {
+	trees := randomTrees(rand.New(rand.NewSource(1)), 50000, 50000, 100000)
+
+	for _, pattern := range Patterns {
+		b.Run(pattern, func(b *testing.B) {
+			traversal, _ := NewTraversal(pattern, CornerSouthWest)
+			p := NewPlanner(NewPlannerOptions{Width: 50000, Length: 50000, Trees: trees, Config: DefaultConfig(), Traversal: traversal})
+			for i := 0; i < b.N; i++ {
+				p.DistanceWithLimit(context.Background(), math.MaxInt)
+			}
+		})
+	}
+}
+
+func BenchmarkMissions_LargestEstate(b *testing.B) {
+	trees := randomTrees(rand.New(rand.NewSource(1)), 50000, 50000, 100000)
+
+	for _, pattern := range Patterns {
+		b.Run(pattern, func(b *testing.B) {
+			traversal, _ := NewTraversal(pattern, CornerSouthWest)
+			p := NewPlanner(NewPlannerOptions{Width: 50000, Length: 50000, Trees: trees, Config: DefaultConfig(), Traversal: traversal})
+			for i := 0; i < b.N; i++ {
+				p.Missions(context.Background(), 1000000000, math.MaxInt)
+			}
+		})
+	}
+}
+
+func BenchmarkPath_LargestEstate(b *testing.B) {
+	trees := randomTrees(rand.New(rand.NewSource(1)), 50000, 50000, 100000)
+
+	for _, pattern := range Patterns {
+		b.Run(pattern, func(b *testing.B) {
+			traversal, _ := NewTraversal(pattern, CornerSouthWest)
+			p := NewPlanner(NewPlannerOptions{Width: 50000, Length: 50000, Trees: trees, Config: DefaultConfig(), Traversal: traversal})
+			for i := 0; i < b.N; i++ {
+				p.Path(context.Background(), func(Waypoint) bool { return true })
+			}
+		})
+	}
+}
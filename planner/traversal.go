@@ -0,0 +1,193 @@
+package planner
+
+import "sort"
+
+// Traversal orders the plots of an estate along the drone route. New patterns
+// only have to implement it to be flown, exported and compared like the
+// built-in ones.
+type Traversal interface {
+	// Plots calls visit for every plot of a width by length estate in flight
+	// order, and stops as soon as visit returns false. Every plot must be
+	// visited once, and consecutive plots should be adjacent since the drone
+	// flies straight from one to the next.
+	Plots(width, length int, visit func(plot Plot) bool)
+}
+
+// Built-in traversal patterns.
+const (
+	// PatternRows sweeps the estate row by row, turning back at every edge.
+	PatternRows = "rows"
+	// PatternColumns sweeps the estate column by column, turning back at
+	// every edge.
+	PatternColumns = "columns"
+	// PatternSpiral follows the border of the estate, then spirals inward.
+	PatternSpiral = "spiral"
+)
+
+// Corners a traversal can start from. The south west corner is plot (1, 1).
+const (
+	CornerSouthWest = "sw"
+	CornerSouthEast = "se"
+	CornerNorthWest = "nw"
+	CornerNorthEast = "ne"
+)
+
+var (
+	Patterns = []string{PatternRows, PatternColumns, PatternSpiral}
+	Corners  = []string{CornerSouthWest, CornerSouthEast, CornerNorthWest, CornerNorthEast}
+)
+
+// DefaultTraversal is the zig-zag route starting from plot (1, 1) and
+// sweeping the rows.
+func DefaultTraversal() Traversal {
+	return boustrophedon{}
+}
+
+// NewTraversal returns the built-in traversal of a pattern starting from a
+// corner. It returns ErrUnknownPattern when either is not supported.
+func NewTraversal(pattern, corner string) (Traversal, error) {
+	var m mirror
+	switch corner {
+	case CornerSouthWest:
+	case CornerSouthEast:
+		m.east = true
+	case CornerNorthWest:
+		m.north = true
+	case CornerNorthEast:
+		m.east, m.north = true, true
+	default:
+		return nil, ErrUnknownPattern
+	}
+
+	switch pattern {
+	case PatternRows:
+		return boustrophedon{mirror: m}, nil
+	case PatternColumns:
+		return boustrophedon{mirror: m, columns: true}, nil
+	case PatternSpiral:
+		return spiral{mirror: m}, nil
+	}
+	return nil, ErrUnknownPattern
+}
+
+// mirror flips a traversal starting from the south west corner so it starts
+// from another corner.
+type mirror struct {
+	east  bool
+	north bool
+}
+
+func (m mirror) apply(width, length int, plot Plot) Plot {
+	if m.east {
+		plot.X = width + 1 - plot.X
+	}
+	if m.north {
+		plot.Y = length + 1 - plot.Y
+	}
+	return plot
+}
+
+// boustrophedon sweeps the estate back and forth, row by row or column by
+// column.
+type boustrophedon struct {
+	mirror  mirror
+	columns bool
+}
+
+func (b boustrophedon) Plots(width, length int, visit func(plot Plot) bool) {
+	lines, span := length, width
+	if b.columns {
+		lines, span = width, length
+	}
+
+	for line := 1; line <= lines; line++ {
+		for i := 0; i < span; i++ {
+			step := i + 1
+			if line%2 == 0 {
+				step = span - i
+			}
+
+			plot := Plot{X: step, Y: line}
+			if b.columns {
+				plot = Plot{X: line, Y: step}
+			}
+
+			if !visit(b.mirror.apply(width, length, plot)) {
+				return
+			}
+		}
+	}
+}
+
+// spiral goes along the border of the estate, eastward first, then along the
+// border of what is left, until it reaches the middle.
+type spiral struct {
+	mirror mirror
+}
+
+func (s spiral) Plots(width, length int, visit func(plot Plot) bool) {
+	left, right, bottom, top := 1, width, 1, length
+	emit := func(x, y int) bool {
+		return visit(s.mirror.apply(width, length, Plot{X: x, Y: y}))
+	}
+
+	for left <= right && bottom <= top {
+		for x := left; x <= right; x++ {
+			if !emit(x, bottom) {
+				return
+			}
+		}
+		for y := bottom + 1; y <= top; y++ {
+			if !emit(right, y) {
+				return
+			}
+		}
+		if bottom < top {
+			for x := right - 1; x >= left; x-- {
+				if !emit(x, top) {
+					return
+				}
+			}
+		}
+		if left < right {
+			for y := top - 1; y > bottom; y-- {
+				if !emit(left, y) {
+					return
+				}
+			}
+		}
+		left, right, bottom, top = left+1, right-1, bottom+1, top-1
+	}
+}
+
+// PatternDistance is the distance of the route flown with a built-in pattern.
+type PatternDistance struct {
+	Pattern  string `json:"pattern"`
+	Corner   string `json:"corner"`
+	Distance int    `json:"distance"`
+}
+
+// ComparePatterns plans the estate with every built-in pattern from every
+// corner, and returns their distance from the shortest to the longest. The
+// traversal of opts is ignored.
+func ComparePatterns(opts NewPlannerOptions) []PatternDistance {
+	// The trees are indexed once and flown with every traversal
+	p := NewPlanner(opts)
+
+	var distances []PatternDistance
+	for _, pattern := range Patterns {
+		for _, corner := range Corners {
+			p.traversal, _ = NewTraversal(pattern, corner)
+			distances = append(distances, PatternDistance{
+				Pattern:  pattern,
+				Corner:   corner,
+				Distance: p.Distance(),
+			})
+		}
+	}
+
+	sort.SliceStable(distances, func(i, j int) bool {
+		return distances[i].Distance < distances[j].Distance
+	})
+	return distances
+}
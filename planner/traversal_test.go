@@ -0,0 +1,111 @@
+package planner
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectPlots(traversal Traversal, width, length int) (plots []Plot) {
+	traversal.Plots(width, length, func(plot Plot) bool {
+		plots = append(plots, plot)
+		return true
+	})
+	return plots
+}
+
+func TestTraversal_VisitsEveryPlotOnceThroughAdjacentPlots(t *testing.T) {
+	for _, pattern := range Patterns {
+		for _, corner := range Corners {
+			traversal, err := NewTraversal(pattern, corner)
+			assert.NoError(t, err)
+
+			for _, size := range [][2]int{{1, 1}, {1, 4}, {4, 1}, {3, 3}, {4, 5}, {6, 3}} {
+				plots := collectPlots(traversal, size[0], size[1])
+				assert.Len(t, plots, size[0]*size[1], "%s from %s on %v", pattern, corner, size)
+
+				seen := make(map[Plot]bool)
+				for i, plot := range plots {
+					assert.False(t, seen[plot], "%s from %s visits %v twice", pattern, corner, plot)
+					seen[plot] = true
+					assert.True(t, plot.X >= 1 && plot.X <= size[0] && plot.Y >= 1 && plot.Y <= size[1])
+					if i > 0 {
+						assert.Equal(t, 1, abs(plot.X-plots[i-1].X)+abs(plot.Y-plots[i-1].Y), "%s from %s jumps to %v", pattern, corner, plot)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestTraversal_StartsFromCorner(t *testing.T) {
+	starts := map[string]Plot{
+		CornerSouthWest: {X: 1, Y: 1},
+		CornerSouthEast: {X: 4, Y: 1},
+		CornerNorthWest: {X: 1, Y: 3},
+		CornerNorthEast: {X: 4, Y: 3},
+	}
+
+	for corner, start := range starts {
+		traversal, _ := NewTraversal(PatternSpiral, corner)
+		assert.Equal(t, start, collectPlots(traversal, 4, 3)[0], corner)
+	}
+}
+
+func TestTraversal_Columns(t *testing.T) {
+	traversal, _ := NewTraversal(PatternColumns, CornerSouthWest)
+
+	assert.Equal(t, []Plot{{1, 1}, {1, 2}, {2, 2}, {2, 1}, {3, 1}, {3, 2}}, collectPlots(traversal, 3, 2))
+}
+
+func TestTraversal_Spiral(t *testing.T) {
+	traversal, _ := NewTraversal(PatternSpiral, CornerSouthWest)
+
+	assert.Equal(t, []Plot{
+		{1, 1}, {2, 1}, {3, 1},
+		{3, 2}, {3, 3},
+		{2, 3}, {1, 3},
+		{1, 2}, {2, 2},
+	}, collectPlots(traversal, 3, 3))
+}
+
+func TestNewTraversal_Unknown(t *testing.T) {
+	_, err := NewTraversal("hilbert", CornerSouthWest)
+	assert.ErrorIs(t, err, ErrUnknownPattern)
+
+	_, err = NewTraversal(PatternRows, "center")
+	assert.ErrorIs(t, err, ErrUnknownPattern)
+}
+
+func TestPlanner_StartsFromTraversalCorner(t *testing.T) {
+	traversal, _ := NewTraversal(PatternRows, CornerNorthEast)
+	p := NewPlanner(NewPlannerOptions{Width: 3, Length: 2, Config: DefaultConfig(), Traversal: traversal})
+
+	_, rest, err := p.DistanceWithLimit(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Plot{X: 3, Y: 2}, rest)
+
+	sorties, err := p.Missions(context.Background(), 1000, math.MaxInt)
+	assert.NoError(t, err)
+	assert.Equal(t, Plot{X: 3, Y: 2}, sorties[0].Start)
+	assert.Equal(t, Plot{X: 3, Y: 1}, sorties[0].End)
+}
+
+func TestComparePatterns_ShortestFirst(t *testing.T) {
+	// A row of tall trees is best flown along, without dipping between them
+	distances := ComparePatterns(NewPlannerOptions{
+		Width:  3,
+		Length: 3,
+		Trees:  []Tree{{X: 1, Y: 1, Height: 20}, {X: 2, Y: 1, Height: 20}, {X: 3, Y: 1, Height: 20}},
+		Config: DefaultConfig(),
+	})
+
+	assert.Len(t, distances, len(Patterns)*len(Corners))
+	for i := 1; i < len(distances); i++ {
+		assert.LessOrEqual(t, distances[i-1].Distance, distances[i].Distance)
+	}
+	assert.NotEqual(t, PatternColumns, distances[0].Pattern)
+	assert.Equal(t, PatternColumns, distances[len(distances)-1].Pattern)
+}
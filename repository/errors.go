@@ -1,8 +1,115 @@
 package repository
 
-import "errors"
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
 
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrPlotOutOfBounds is returned when a tree or a zone lies outside its
+	// estate
+	ErrPlotOutOfBounds = errors.New("plot out of the estate bounds")
+	// ErrTreesOutOfBounds is returned when resizing an estate would leave
+	// some of its trees outside
+	ErrTreesOutOfBounds = errors.New("trees out of the new estate bounds")
+	// ErrZonesOutOfBounds is returned when resizing an estate would leave
+	// some of its zones outside
+	ErrZonesOutOfBounds = errors.New("zones out of the new estate bounds")
+	// ErrZoneOverlaps is returned when a zone overlaps another one of its
+	// estate
+	ErrZoneOverlaps = errors.New("zone overlaps another zone")
+	// ErrMeasuredBeforePlanting is returned when a measurement is dated
+	// before its tree was planted
+	ErrMeasuredBeforePlanting = errors.New("measured before the tree was planted")
+)
+
+// The errors below stand for the errors of the database, which they wrap, so
+// that callers need not know about the database.
 var (
-	ErrForeignKeyNotFound = errors.New("related resource not found")
-	ErrDatabaseError      = errors.New("database error")
+	ErrEstateNotFound      = errors.New("estate not found")
+	ErrTreeNotFound        = errors.New("tree not found")
+	ErrZoneNotFound        = errors.New("zone not found")
+	ErrDronePlanNotFound   = errors.New("no stored drone plan")
+	ErrDroneConfigNotFound = errors.New("no drone config")
+	// ErrPlotOccupied is returned when a tree is planted or moved on a plot
+	// that already has one
+	ErrPlotOccupied = errors.New("plot already has a tree")
+	// ErrZoneNameTaken is returned when a zone is named like another one of
+	// its estate
+	ErrZoneNameTaken = errors.New("zone name already taken")
+	// ErrConflict is returned when Postgres aborts a write on a serialization
+	// failure or a deadlock with a concurrent one, which may be retried
+	ErrConflict = errors.New("conflict with a concurrent write")
+	// ErrUnavailable is returned when the database cannot be reached
+	ErrUnavailable = errors.New("database unavailable")
+	// ErrTimeout is returned when the database gives up on a query that runs
+	// or waits for a lock for too long
+	ErrTimeout = errors.New("database query timed out")
 )
+
+var translated = []error{ErrEstateNotFound, ErrTreeNotFound, ErrZoneNotFound, ErrDronePlanNotFound, ErrDroneConfigNotFound, ErrPlotOccupied, ErrZoneNameTaken, ErrConflict, ErrUnavailable, ErrTimeout}
+
+// translate replaces the database error in *err, if any, with the error of
+// the repository it stands for. sql.ErrNoRows stands for notFound, unless
+// nil. Errors of the context are left as they are.
+func translate(err *error, notFound error) {
+	if *err == nil {
+		return
+	}
+	for _, kind := range translated {
+		if errors.Is(*err, kind) {
+			return
+		}
+	}
+
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(*err, context.Canceled) || errors.Is(*err, context.DeadlineExceeded):
+	case errors.Is(*err, sql.ErrNoRows):
+		if notFound != nil {
+			*err = wrap(notFound, *err)
+		}
+	case retryable(*err):
+		*err = wrap(ErrConflict, *err)
+	case errors.As(*err, &pqErr):
+		switch pqErr.Code {
+		case "23505": // unique_violation
+			// The only unique index on the trees is on their plot
+			switch {
+			case pqErr.Table == "trees":
+				*err = wrap(ErrPlotOccupied, *err)
+			case pqErr.Constraint == "zones_estate_id_name_key":
+				*err = wrap(ErrZoneNameTaken, *err)
+			}
+		case "23503": // foreign_key_violation
+			*err = wrap(ErrEstateNotFound, *err)
+		case "57P01", "57P02", "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+			*err = wrap(ErrUnavailable, *err)
+		case "57014", "55P03": // query_canceled, lock_not_available
+			*err = wrap(ErrTimeout, *err)
+		default:
+			if class := pqErr.Code.Class(); class == "08" || class == "53" { // connection_exception, insufficient_resources
+				*err = wrap(ErrUnavailable, *err)
+			}
+		}
+	case unreachable(*err):
+		*err = wrap(ErrUnavailable, *err)
+	}
+}
+
+// unreachable tells whether err comes from the connection to the database
+// rather than from the database itself.
+func unreachable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr)
+}
+
+func wrap(kind, cause error) error {
+	return fmt.Errorf("%w: %w", kind, cause)
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		notFound error
+		// want is the error of the repository err stands for, nil when err
+		// is left as it is
+		want error
+	}{
+		{"no rows", sql.ErrNoRows, ErrTreeNotFound, ErrTreeNotFound},
+		{"no rows without not found", sql.ErrNoRows, nil, nil},
+		{"canceled", context.Canceled, ErrTreeNotFound, nil},
+		{"deadline exceeded", context.DeadlineExceeded, nil, nil},
+		{"translated already", wrap(ErrZoneNotFound, sql.ErrNoRows), ErrTreeNotFound, ErrZoneNotFound},
+		{"plot of a tree taken", &pq.Error{Code: "23505", Table: "trees", Constraint: "idx_trees_plot"}, nil, ErrPlotOccupied},
+		{"zone name taken", &pq.Error{Code: "23505", Table: "zones", Constraint: "zones_estate_id_name_key"}, nil, ErrZoneNameTaken},
+		{"other unique violation", &pq.Error{Code: "23505", Table: "drone_plans", Constraint: "drone_plans_estate_id_key"}, nil, nil},
+		{"estate gone", &pq.Error{Code: "23503", Table: "trees"}, nil, ErrEstateNotFound},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, nil, ErrUnavailable},
+		{"crash shutdown", &pq.Error{Code: "57P02"}, nil, ErrUnavailable},
+		{"cannot connect now", &pq.Error{Code: "57P03"}, nil, ErrUnavailable},
+		{"statement timeout", &pq.Error{Code: "57014"}, nil, ErrTimeout},
+		{"lock timeout", &pq.Error{Code: "55P03"}, nil, ErrTimeout},
+		{"connection failure", &pq.Error{Code: "08006"}, nil, ErrUnavailable},
+		{"too many connections", &pq.Error{Code: "53300"}, nil, ErrUnavailable},
+		{"serialization failure", &pq.Error{Code: "40001"}, nil, ErrConflict},
+		{"deadlock", &pq.Error{Code: "40P01"}, nil, ErrConflict},
+		{"syntax error", &pq.Error{Code: "42601"}, nil, nil},
+		{"bad connection", driver.ErrBadConn, nil, ErrUnavailable},
+		{"connection done", sql.ErrConnDone, nil, ErrUnavailable},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, nil, ErrUnavailable},
+	} {
+		err := tc.err
+		translate(&err, tc.notFound)
+
+		assert.ErrorIs(t, err, tc.err, tc.name)
+		if tc.want == nil {
+			assert.Equal(t, tc.err, err, tc.name)
+			continue
+		}
+		assert.ErrorIs(t, err, tc.want, tc.name)
+		for _, kind := range translated {
+			if kind != tc.want {
+				assert.NotErrorIs(t, err, kind, tc.name)
+			}
+		}
+	}
+}
+
+func TestTranslate_Nil(t *testing.T) {
+	var err error
+	translate(&err, ErrTreeNotFound)
+
+	assert.NoError(t, err)
+}
@@ -2,47 +2,957 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/unklejo/swpr.drone/planner"
 )
 
 func (r *Repository) GetTestById(ctx context.Context, input GetTestByIdInput) (output GetTestByIdOutput, err error) {
-	err = r.Db.QueryRowContext(ctx, "SELECT name FROM test WHERE id = $1", input.Id).Scan(&output.Name)
+	err = r.db().QueryRowContext(ctx, "SELECT name FROM test WHERE id = $1", input.Id).Scan(&output.Name)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func (r *Repository) CreateEstate(width, length int) (id string, err error) {
-	err = r.Db.QueryRow("INSERT INTO estates (width, length) VALUES ($1, $2) RETURNING id", width, length).Scan(&id)
+func (r *Repository) CreateEstate(ctx context.Context, width, length int, geo *GeoReference) (id string, err error) {
+	defer translate(&err, nil)
+
+	var latitude, longitude, bearing sql.NullFloat64
+	if geo != nil {
+		latitude = sql.NullFloat64{Float64: geo.Latitude, Valid: true}
+		longitude = sql.NullFloat64{Float64: geo.Longitude, Valid: true}
+		bearing = sql.NullFloat64{Float64: geo.Bearing, Valid: true}
+	}
+
+	// The drone plan of the new, empty, estate is stored right away so that
+	// AddTree only has to update it
+	distance := planner.DefaultConfig().EmptyDistance(width, length)
+	err = r.db().QueryRowContext(ctx, "WITH estate AS (INSERT INTO estates (width, length, origin_latitude, origin_longitude, bearing) VALUES ($1, $2, $3, $4, $5) RETURNING id) INSERT INTO drone_plans (estate_id, distance) SELECT id, $6 FROM estate RETURNING estate_id", width, length, latitude, longitude, bearing, distance).Scan(&id)
 	return id, err
 }
 
-func (r *Repository) AddTree(estateId string, x, y, height int) (id string, err error) {
-	err = r.Db.QueryRow("INSERT INTO trees (estate_id, x_coordinate, y_coordinate, height) VALUES ($1, $2, $3, $4) RETURNING id", estateId, x, y, height).Scan(&id)
-	return id, err
+// AddTree returns ErrPlotOutOfBounds when the plot lies outside the estate,
+// and ErrPlotOccupied when the plot already has a tree. It also updates the
+// stored drone plan of the estate in the same transaction, so that it never
+// goes out of step with the trees.
+func (r *Repository) AddTree(ctx context.Context, estateId string, x, y, height int) (id string, err error) {
+	defer translate(&err, ErrEstateNotFound)
+
+	tx, err := r.begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var width, length int
+	if err = tx.QueryRowContext(ctx, "SELECT width, length FROM estates WHERE id = $1 FOR SHARE", estateId).Scan(&width, &length); err != nil {
+		return "", err
+	}
+	if x > width || y > length {
+		return "", ErrPlotOutOfBounds
+	}
+
+	// The height at planting is the first measurement of the tree
+	err = tx.QueryRowContext(ctx, "WITH planted AS (INSERT INTO trees (estate_id, x_coordinate, y_coordinate, height) VALUES ($1, $2, $3, $4) RETURNING id, height), measured AS (INSERT INTO tree_measurements (tree_id, height, source) SELECT id, height, $5 FROM planted) SELECT id FROM planted", estateId, x, y, height, SourcePlanting).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	if err = updateDronePlan(ctx, tx, estateId, planner.Plot{X: x, Y: y}, 0, height); err != nil {
+		return "", err
+	}
+
+	return id, tx.Commit()
+}
+
+// updateDronePlan adds to the stored drone plan the distance the drone flies
+// more, or less, when the tree on a plot goes from one height to another, 0
+// standing for an empty plot. Only the legs to and from that plot change, so
+// only the trees of the plots flown right before and after it are read.
+// Estates without a stored plan get it computed on the next request.
+func updateDronePlan(ctx context.Context, tx querier, estateId string, plot planner.Plot, before, after int) error {
+	// Lock the plan first, so that the neighbour trees read below include the
+	// ones planted by concurrent transactions that updated the plan before us
+	var width, length int
+	var clearance, takeoffAltitude sql.NullInt64
+	err := tx.QueryRowContext(ctx, "SELECT e.width, e.length, dc.clearance, dc.takeoff_altitude FROM drone_plans dp JOIN estates e ON e.id = dp.estate_id LEFT JOIN drone_configs dc ON dc.estate_id = dp.estate_id WHERE dp.estate_id = $1 FOR UPDATE OF dp", estateId).Scan(&width, &length, &clearance, &takeoffAltitude)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	config := planner.DefaultConfig()
+	if clearance.Valid {
+		config.Clearance = int(clearance.Int64)
+		config.TakeoffAltitude = int(takeoffAltitude.Int64)
+	}
+
+	// Plot (0, 0) never holds a tree, it stands in for a missing neighbour
+	previousPlot, hasPrevious, nextPlot, hasNext := planner.ZigZagNeighbours(width, length, plot)
+	rows, err := tx.QueryContext(ctx, "SELECT x_coordinate, y_coordinate, height FROM trees WHERE estate_id = $1 AND deleted_at IS NULL AND ((x_coordinate = $2 AND y_coordinate = $3) OR (x_coordinate = $4 AND y_coordinate = $5))", estateId, previousPlot.X, previousPlot.Y, nextPlot.X, nextPlot.Y)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var previous, next *int
+	if hasPrevious {
+		previous = &config.Clearance
+	}
+	if hasNext {
+		next = &config.Clearance
+	}
+	for rows.Next() {
+		var neighbour planner.Plot
+		var neighbourHeight int
+		if err = rows.Scan(&neighbour.X, &neighbour.Y, &neighbourHeight); err != nil {
+			return err
+		}
+
+		altitude := neighbourHeight + config.Clearance
+		if hasPrevious && neighbour == previousPlot {
+			previous = &altitude
+		} else if hasNext && neighbour == nextPlot {
+			next = &altitude
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	delta := config.AltitudeDelta(previous, next, before+config.Clearance, after+config.Clearance)
+	_, err = tx.ExecContext(ctx, "UPDATE drone_plans SET distance = distance + $2 WHERE estate_id = $1", estateId, delta)
+	return err
+}
+
+// ImportTrees plants trees in bulk, in a single transaction, and returns the
+// outcome of every tree in order. Trees whose plot already has one are
+// skipped, or cancel the whole import when atomic, in which case no id is
+// returned. It returns ErrPlotOutOfBounds when a tree lies outside the
+// estate. The stored drone plan is dropped, to be recomputed on demand.
+func (r *Repository) ImportTrees(ctx context.Context, estateId string, trees []Tree, atomic bool) (imported []ImportedTree, err error) {
+	defer translate(&err, ErrEstateNotFound)
+
+	tx, err := r.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var width, length int
+	if err = tx.QueryRowContext(ctx, "SELECT width, length FROM estates WHERE id = $1 FOR SHARE", estateId).Scan(&width, &length); err != nil {
+		return nil, err
+	}
+
+	// The trees are copied to a temporary table first, since COPY cannot
+	// skip the plots that already have a tree
+	if _, err = tx.ExecContext(ctx, "CREATE TEMPORARY TABLE imported_trees (position INTEGER, x_coordinate INTEGER, y_coordinate INTEGER, height INTEGER) ON COMMIT DROP"); err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("imported_trees", "position", "x_coordinate", "y_coordinate", "height"))
+	if err != nil {
+		return nil, err
+	}
+	for i, tree := range trees {
+		if tree.X > width || tree.Y > length {
+			stmt.Close()
+			return nil, ErrPlotOutOfBounds
+		}
+		if _, err = stmt.ExecContext(ctx, i, tree.X, tree.Y, tree.Height); err != nil {
+			stmt.Close()
+			return nil, err
+		}
+	}
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return nil, err
+	}
+	if err = stmt.Close(); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "WITH planted AS (INSERT INTO trees (estate_id, x_coordinate, y_coordinate, height) SELECT $1, x_coordinate, y_coordinate, height FROM imported_trees ORDER BY position ON CONFLICT (estate_id, x_coordinate, y_coordinate) WHERE deleted_at IS NULL DO NOTHING RETURNING id, x_coordinate, y_coordinate, height), measured AS (INSERT INTO tree_measurements (tree_id, height, source) SELECT id, height, $2 FROM planted) SELECT id, x_coordinate, y_coordinate FROM planted", estateId, SourceImport)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	planted := make(map[planner.Plot]string, len(trees))
+	count := 0
+	for rows.Next() {
+		var id string
+		var plot planner.Plot
+		if err = rows.Scan(&id, &plot.X, &plot.Y); err != nil {
+			return nil, err
+		}
+		planted[plot] = id
+		count++
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The table would only be dropped along with the unit of work otherwise,
+	// which may import trees again
+	if _, err = tx.ExecContext(ctx, "DROP TABLE imported_trees"); err != nil {
+		return nil, err
+	}
+
+	// A plot listed twice is planted with its first tree only
+	imported = make([]ImportedTree, len(trees))
+	occupied := false
+	for i, tree := range trees {
+		plot := planner.Plot{X: tree.X, Y: tree.Y}
+		if id, ok := planted[plot]; ok {
+			imported[i].Id = id
+			delete(planted, plot)
+		} else {
+			imported[i].Occupied = true
+			occupied = true
+		}
+	}
+
+	if occupied && atomic {
+		for i := range imported {
+			imported[i].Id = ""
+		}
+		return imported, nil
+	}
+
+	if count > 0 {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM drone_plans WHERE estate_id = $1", estateId); err != nil {
+			return nil, err
+		}
+	}
+
+	return imported, tx.Commit()
+}
+
+// UpdateTree moves a tree and changes its height, and returns the tree as
+// stored, or ErrPlotOutOfBounds when the new plot lies outside the estate
+// and ErrPlotOccupied when it has another tree. Like AddTree, it keeps the
+// stored drone plan in step.
+func (r *Repository) UpdateTree(ctx context.Context, estateId string, tree Tree) (updated Tree, err error) {
+	defer translate(&err, ErrTreeNotFound)
+
+	tx, err := r.begin(ctx)
+	if err != nil {
+		return updated, err
+	}
+	defer tx.Rollback()
+
+	var width, length int
+	err = tx.QueryRowContext(ctx, "SELECT width, length FROM estates WHERE id = $1 FOR SHARE", estateId).Scan(&width, &length)
+	if errors.Is(err, sql.ErrNoRows) {
+		return updated, wrap(ErrEstateNotFound, err)
+	}
+	if err != nil {
+		return updated, err
+	}
+	if tree.X > width || tree.Y > length {
+		return updated, ErrPlotOutOfBounds
+	}
+
+	var previous Tree
+	err = tx.QueryRowContext(ctx, "SELECT x_coordinate, y_coordinate, height FROM trees WHERE id = $1 AND estate_id = $2 AND deleted_at IS NULL FOR UPDATE", tree.Id, estateId).Scan(&previous.X, &previous.Y, &previous.Height)
+	if err != nil {
+		return updated, err
+	}
+
+	from := planner.Plot{X: previous.X, Y: previous.Y}
+	to := planner.Plot{X: tree.X, Y: tree.Y}
+
+	// A moved tree is uprooted before the move, and planted after it, so that
+	// each plan update sees the other plot as it is then
+	before := previous.Height
+	if from != to {
+		if err = updateDronePlan(ctx, tx, estateId, from, previous.Height, 0); err != nil {
+			return updated, err
+		}
+		before = 0
+	}
+
+	row := tx.QueryRowContext(ctx, "UPDATE trees SET x_coordinate = $3, y_coordinate = $4, height = $5, updated_at = NOW() WHERE id = $1 AND estate_id = $2 RETURNING "+treeColumns, tree.Id, estateId, tree.X, tree.Y, tree.Height)
+	if err = scanTree(row, &updated); err != nil {
+		return updated, err
+	}
+
+	// A corrected height goes down in the history like any measurement
+	if tree.Height != previous.Height {
+		_, err = tx.ExecContext(ctx, "INSERT INTO tree_measurements (tree_id, height, source) VALUES ($1, $2, $3)", tree.Id, tree.Height, SourceCorrection)
+		if err != nil {
+			return updated, err
+		}
+	}
+
+	if err = updateDronePlan(ctx, tx, estateId, to, before, tree.Height); err != nil {
+		return updated, err
+	}
+
+	return updated, tx.Commit()
+}
+
+// RecordMeasurement adds a measurement to the history of a tree. It returns
+// ErrTreeNotFound when the tree is not in the estate, and
+// ErrMeasuredBeforePlanting when the measurement predates the tree. The
+// latest measurement is the current height of the tree, so a measurement
+// newer than every other one also updates the tree, and the stored drone plan
+// along.
+func (r *Repository) RecordMeasurement(ctx context.Context, estateId, treeId string, measurement Measurement) (recorded Measurement, err error) {
+	defer translate(&err, ErrTreeNotFound)
+
+	tx, err := r.begin(ctx)
+	if err != nil {
+		return recorded, err
+	}
+	defer tx.Rollback()
+
+	var id string
+	if err = tx.QueryRowContext(ctx, "SELECT id FROM estates WHERE id = $1 FOR SHARE", estateId).Scan(&id); err != nil {
+		return recorded, err
+	}
+
+	var tree Tree
+	var latest sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT t.x_coordinate, t.y_coordinate, t.height, t.created_at, (SELECT MAX(m.measured_at) FROM tree_measurements m WHERE m.tree_id = t.id) FROM trees t WHERE t.id = $1 AND t.estate_id = $2 AND t.deleted_at IS NULL FOR UPDATE", treeId, estateId).Scan(&tree.X, &tree.Y, &tree.Height, &tree.CreatedAt, &latest)
+	if err != nil {
+		return recorded, err
+	}
+	if measurement.MeasuredAt.Before(tree.CreatedAt) {
+		return recorded, ErrMeasuredBeforePlanting
+	}
+
+	err = tx.QueryRowContext(ctx, "INSERT INTO tree_measurements (tree_id, height, measured_at, source) VALUES ($1, $2, $3, $4) RETURNING "+measurementColumns, treeId, measurement.Height, measurement.MeasuredAt, measurement.Source).Scan(&recorded.Id, &recorded.Height, &recorded.MeasuredAt, &recorded.Source)
+	if err != nil {
+		return recorded, err
+	}
+
+	if latest.Valid && recorded.MeasuredAt.Before(latest.Time) {
+		return recorded, tx.Commit()
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE trees SET height = $2, updated_at = NOW() WHERE id = $1", treeId, recorded.Height); err != nil {
+		return recorded, err
+	}
+	if err = updateDronePlan(ctx, tx, estateId, planner.Plot{X: tree.X, Y: tree.Y}, tree.Height, recorded.Height); err != nil {
+		return recorded, err
+	}
+
+	return recorded, tx.Commit()
+}
+
+// GetMeasurementsByTreeId returns the history of a tree, oldest first.
+func (r *Repository) GetMeasurementsByTreeId(ctx context.Context, estateId, treeId string) (measurements []Measurement, err error) {
+	defer translate(&err, nil)
+
+	rows, err := r.db().QueryContext(ctx, "SELECT "+measurementColumns+" FROM tree_measurements WHERE tree_id = (SELECT id FROM trees WHERE id = $1 AND estate_id = $2 AND deleted_at IS NULL) ORDER BY measured_at, created_at", treeId, estateId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var measurement Measurement
+		if err = rows.Scan(&measurement.Id, &measurement.Height, &measurement.MeasuredAt, &measurement.Source); err != nil {
+			return nil, err
+		}
+		measurements = append(measurements, measurement)
+	}
+	return measurements, rows.Err()
+}
+
+const measurementColumns = "id, height, measured_at, source"
+
+// DeleteTree returns ErrTreeNotFound when the tree is not in the estate. The
+// tree is only marked as deleted, so that GetEstateStatsAsOf still counts it
+// before its removal. Like AddTree, it keeps the stored drone plan in step.
+func (r *Repository) DeleteTree(ctx context.Context, estateId, treeId string) (err error) {
+	defer translate(&err, ErrTreeNotFound)
+
+	tx, err := r.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id string
+	if err = tx.QueryRowContext(ctx, "SELECT id FROM estates WHERE id = $1 FOR SHARE", estateId).Scan(&id); err != nil {
+		return err
+	}
+
+	var tree Tree
+	err = tx.QueryRowContext(ctx, "UPDATE trees SET deleted_at = NOW() WHERE id = $1 AND estate_id = $2 AND deleted_at IS NULL RETURNING x_coordinate, y_coordinate, height", treeId, estateId).Scan(&tree.X, &tree.Y, &tree.Height)
+	if err != nil {
+		return err
+	}
+
+	if err = updateDronePlan(ctx, tx, estateId, planner.Plot{X: tree.X, Y: tree.Y}, tree.Height, 0); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEstate scans the estate columns selected by GetEstateById, followed by
+// the extra destinations.
+func scanEstate(row rowScanner, estate *Estate, extra ...any) error {
+	var latitude, longitude, bearing sql.NullFloat64
+	dest := append([]any{&estate.Id, &estate.Width, &estate.Length, &latitude, &longitude, &bearing, &estate.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	if latitude.Valid && longitude.Valid && bearing.Valid {
+		estate.GeoReference = &GeoReference{Latitude: latitude.Float64, Longitude: longitude.Float64, Bearing: bearing.Float64}
+	}
+	return nil
+}
+
+func (r *Repository) GetEstateById(ctx context.Context, id string) (estate Estate, err error) {
+	defer translate(&err, ErrEstateNotFound)
+
+	row := r.db().QueryRowContext(ctx, "SELECT id, width, length, origin_latitude, origin_longitude, bearing, created_at FROM estates WHERE id = $1", id)
+	err = scanEstate(row, &estate)
+	return estate, err
+}
+
+// LockEstate returns the estate like GetEstateById, and keeps it locked until
+// the end of the unit of work, see WithTx. The writes of the trees and of the
+// drone config of an estate take a share lock on it, so they run alongside
+// each other but wait for a resize, which locks the estate like LockEstate,
+// and for the unit of work, e.g. to store a drone plan computed from them.
+func (r *Repository) LockEstate(ctx context.Context, id string) (estate Estate, err error) {
+	defer translate(&err, ErrEstateNotFound)
+
+	row := r.db().QueryRowContext(ctx, "SELECT id, width, length, origin_latitude, origin_longitude, bearing, created_at FROM estates WHERE id = $1 FOR UPDATE", id)
+	err = scanEstate(row, &estate)
+	return estate, err
+}
+
+// ListEstates returns a page of estates ordered by creation time, each with
+// its tree count.
+func (r *Repository) ListEstates(ctx context.Context, offset, limit int, ascending bool) (estates []Estate, err error) {
+	defer translate(&err, nil)
+
+	order := "DESC"
+	if ascending {
+		order = "ASC"
+	}
+
+	rows, err := r.db().QueryContext(ctx, "SELECT e.id, e.width, e.length, e.origin_latitude, e.origin_longitude, e.bearing, e.created_at, (SELECT COUNT(*) FROM trees t WHERE t.estate_id = e.id AND t.deleted_at IS NULL) FROM estates e ORDER BY e.created_at "+order+", e.id "+order+" LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var estate Estate
+		if err = scanEstate(rows, &estate, &estate.TreeCount); err != nil {
+			return nil, err
+		}
+		estates = append(estates, estate)
+	}
+	return estates, rows.Err()
+}
+
+// UpdateEstate stores the size and the geo-reference of an estate. It returns
+// ErrTreesOutOfBounds when some trees would be left outside the new bounds,
+// and ErrZonesOutOfBounds for some zones. It also drops the stored drone plan
+// of the estate when the size changes, since the route changes with it.
+func (r *Repository) UpdateEstate(ctx context.Context, estate Estate) (err error) {
+	defer translate(&err, ErrEstateNotFound)
+
+	tx, err := r.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the estate so that no tree is planted with the previous bounds
+	// until the resize is committed, see AddTree
+	var width, length int
+	if err = tx.QueryRowContext(ctx, "SELECT width, length FROM estates WHERE id = $1 FOR UPDATE", estate.Id).Scan(&width, &length); err != nil {
+		return err
+	}
+
+	var orphaned bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM trees WHERE estate_id = $1 AND deleted_at IS NULL AND (x_coordinate > $2 OR y_coordinate > $3))", estate.Id, estate.Width, estate.Length).Scan(&orphaned)
+	if err != nil {
+		return err
+	}
+	if orphaned {
+		return ErrTreesOutOfBounds
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM zones WHERE estate_id = $1 AND (x2 > $2 OR y2 > $3))", estate.Id, estate.Width, estate.Length).Scan(&orphaned)
+	if err != nil {
+		return err
+	}
+	if orphaned {
+		return ErrZonesOutOfBounds
+	}
+
+	var latitude, longitude, bearing sql.NullFloat64
+	if geo := estate.GeoReference; geo != nil {
+		latitude = sql.NullFloat64{Float64: geo.Latitude, Valid: true}
+		longitude = sql.NullFloat64{Float64: geo.Longitude, Valid: true}
+		bearing = sql.NullFloat64{Float64: geo.Bearing, Valid: true}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE estates SET width = $2, length = $3, origin_latitude = $4, origin_longitude = $5, bearing = $6, updated_at = NOW() WHERE id = $1", estate.Id, estate.Width, estate.Length, latitude, longitude, bearing)
+	if err != nil {
+		return err
+	}
+	if estate.Width != width || estate.Length != length {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM drone_plans WHERE estate_id = $1", estate.Id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (r *Repository) GetEstateById(id string) (estate Estate, err error) {
-	err = r.Db.QueryRow("SELECT id, width, length FROM estates WHERE id = $1", id).Scan(&estate.Id, &estate.Width, &estate.Length)
+// DeleteEstate returns ErrEstateNotFound when the estate does not exist. Its
+// trees, drone plan and drone config are deleted along.
+func (r *Repository) DeleteEstate(ctx context.Context, id string) (err error) {
+	defer translate(&err, ErrEstateNotFound)
+
+	result, err := r.db().ExecContext(ctx, "DELETE FROM estates WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
 	if err != nil {
-		return estate, err
+		return err
+	}
+	if deleted == 0 {
+		return ErrEstateNotFound
+	}
+	return nil
+}
+
+func (r *Repository) CountTreesByEstateId(ctx context.Context, estateId string) (count int, err error) {
+	defer translate(&err, nil)
+
+	err = r.db().QueryRowContext(ctx, "SELECT COUNT(*) FROM trees WHERE estate_id = $1 AND deleted_at IS NULL", estateId).Scan(&count)
+	return count, err
+}
+
+func (r *Repository) GetEstateStatsById(ctx context.Context, estateId string, options StatsOptions) (stats EstateStats, err error) {
+	defer translate(&err, nil)
+
+	heights, args := inBounds("SELECT t.height FROM trees t WHERE t.estate_id = $1 AND t.deleted_at IS NULL", []any{estateId}, options.Bounds)
+	return r.queryEstateStats(ctx, heights, args, options)
+}
+
+// GetEstateStatsAsOf computes the stats of an estate at a point in time,
+// from the trees planted by then and not yet removed, at their latest
+// measurement. The density follows the current size of the estate.
+func (r *Repository) GetEstateStatsAsOf(ctx context.Context, estateId string, asOf time.Time, options StatsOptions) (stats EstateStats, err error) {
+	defer translate(&err, nil)
+
+	heights, args := inBounds("SELECT DISTINCT ON (m.tree_id) m.height FROM tree_measurements m JOIN trees t ON t.id = m.tree_id WHERE t.estate_id = $1 AND t.created_at <= $2 AND (t.deleted_at IS NULL OR t.deleted_at > $2) AND m.measured_at <= $2", []any{estateId, asOf}, options.Bounds)
+	return r.queryEstateStats(ctx, heights+" ORDER BY m.tree_id, m.measured_at DESC, m.created_at DESC", args, options)
+}
+
+// inBounds restricts a query on the trees t to the plots within bounds, if
+// any.
+func inBounds(query string, args []any, bounds *Bounds) (string, []any) {
+	if bounds == nil {
+		return query, args
+	}
+
+	args = append(args, bounds.X1, bounds.X2, bounds.Y1, bounds.Y2)
+	n := len(args)
+	query += fmt.Sprintf(" AND t.x_coordinate BETWEEN $%d AND $%d AND t.y_coordinate BETWEEN $%d AND $%d", n-3, n-2, n-1, n)
+	return query, args
+}
+
+// queryEstateStats computes the stats of the heights selected by the given
+// query, whose first argument is the estate id. Everything is computed by the
+// database in a single query, the columns asked for in options being appended
+// to the select list.
+func (r *Repository) queryEstateStats(ctx context.Context, heights string, args []any, options StatsOptions) (stats EstateStats, err error) {
+	query := "WITH heights AS (" + heights + ") SELECT COUNT(*), COALESCE(MAX(height), 0), COALESCE(MIN(height), 0), ROUND(COALESCE(PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY height), 0))"
+	dest := []any{&stats.Count, &stats.MaxHeight, &stats.MinHeight, &stats.MedianHeight}
+
+	if options.Mean {
+		stats.MeanHeight = new(float64)
+		query += ", COALESCE(AVG(height), 0)"
+		dest = append(dest, stats.MeanHeight)
+	}
+	if options.StdDev {
+		stats.StdDevHeight = new(float64)
+		query += ", COALESCE(STDDEV_POP(height), 0)"
+		dest = append(dest, stats.StdDevHeight)
 	}
-	return estate, nil
+
+	var percentiles pq.Float64Array
+	if len(options.Percentiles) > 0 {
+		fractions := make(pq.Float64Array, len(options.Percentiles))
+		for i, percentile := range options.Percentiles {
+			fractions[i] = percentile / 100
+		}
+		args = append(args, fractions)
+		query += fmt.Sprintf(", PERCENTILE_CONT($%d::DOUBLE PRECISION[]) WITHIN GROUP (ORDER BY height)", len(args))
+		dest = append(dest, &percentiles)
+	}
+
+	var histogram []byte
+	if options.BucketWidth > 0 {
+		args = append(args, options.BucketWidth)
+		query += ", " + histogramColumn(len(args))
+		dest = append(dest, &histogram)
+	}
+
+	// The density is over the whole estate, or over the bounds when set
+	if options.Density {
+		stats.Density = &Density{}
+		plots := "e.width::DOUBLE PRECISION * e.length"
+		if b := options.Bounds; b != nil {
+			args = append(args, (b.X2-b.X1+1)*(b.Y2-b.Y1+1))
+			plots = fmt.Sprintf("$%d::DOUBLE PRECISION", len(args))
+		}
+		args = append(args, planner.DefaultConfig().PlotSize)
+		query += fmt.Sprintf(", COUNT(*) / (SELECT %[1]s FROM estates e WHERE e.id = $1), COUNT(*) * 10000 / (SELECT %[1]s * COALESCE(c.plot_size, $%[2]d::INTEGER) ^ 2 FROM estates e LEFT JOIN drone_configs c ON c.estate_id = e.id WHERE e.id = $1)", plots, len(args))
+		dest = append(dest, &stats.Density.PerPlot, &stats.Density.PerHectare)
+	}
+
+	if err = r.db().QueryRowContext(ctx, query+" FROM heights", args...).Scan(dest...); err != nil {
+		return stats, err
+	}
+
+	if len(options.Percentiles) > 0 {
+		stats.Percentiles = make(map[string]float64, len(options.Percentiles))
+		for i, percentile := range options.Percentiles {
+			key := "p" + strconv.FormatFloat(percentile, 'f', -1, 64)
+			stats.Percentiles[key] = 0
+			if i < len(percentiles) {
+				stats.Percentiles[key] = percentiles[i]
+			}
+		}
+	}
+	if histogram != nil {
+		if err = json.Unmarshal(histogram, &stats.Histogram); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// histogramColumn selects the histogram of the heights CTE as a JSON array,
+// with the bucket width in meters as the n-th argument. Buckets are listed
+// from the lowest tree to the tallest, empty ones included.
+func histogramColumn(n int) string {
+	return fmt.Sprintf("(SELECT COALESCE(JSON_AGG(JSON_BUILD_OBJECT('from', b * $%[1]d::INTEGER, 'to', (b + 1) * $%[1]d::INTEGER, 'count', c) ORDER BY b), '[]') FROM (SELECT b, COUNT(h.height) AS c FROM GENERATE_SERIES((SELECT MIN(height) FROM heights) / $%[1]d::INTEGER, (SELECT MAX(height) FROM heights) / $%[1]d::INTEGER) AS b LEFT JOIN heights h ON h.height / $%[1]d::INTEGER = b GROUP BY b) AS buckets)", n)
 }
 
-func (r *Repository) GetEstateStatsById(estateId string) (stats EstateStats, err error) {
-	err = r.Db.QueryRow("SELECT COUNT(id), COALESCE(MAX(height), 0), COALESCE(MIN(height), 0), COALESCE(PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY height), 0) FROM trees WHERE estate_id = $1", estateId).Scan(&stats.Count, &stats.MaxHeight, &stats.MinHeight, &stats.MedianHeight)
+// GetPortfolioStats computes the stats of the estates matching filter as a
+// whole, along with the top estates by tallest tree and by density. It is a
+// single query, however many estates there are.
+func (r *Repository) GetPortfolioStats(ctx context.Context, filter PortfolioFilter, top, bucketWidth int) (stats PortfolioStats, err error) {
+	defer translate(&err, nil)
+
+	var estateIds any
+	if filter.EstateIds != nil {
+		estateIds = pq.StringArray(filter.EstateIds)
+	}
+
+	var histogram, tallest, densest []byte
+	err = r.db().QueryRowContext(ctx, "WITH selected AS (SELECT id, width, length FROM estates WHERE ($1::UUID[] IS NULL OR id = ANY($1::UUID[])) AND ($2::TIMESTAMPTZ IS NULL OR created_at >= $2) AND ($3::TIMESTAMPTZ IS NULL OR created_at <= $3)), "+
+		"heights AS (SELECT t.estate_id, t.height FROM trees t JOIN selected s ON s.id = t.estate_id WHERE t.deleted_at IS NULL), "+
+		"per_estate AS (SELECT s.id, s.width::BIGINT * s.length AS plots, COUNT(h.height) AS trees, COALESCE(MAX(h.height), 0) AS max_height FROM selected s LEFT JOIN heights h ON h.estate_id = s.id GROUP BY s.id, s.width, s.length) "+
+		"SELECT (SELECT COUNT(*) FROM selected), (SELECT COALESCE(SUM(plots), 0) FROM per_estate), "+
+		"COUNT(*), COALESCE(MAX(height), 0), COALESCE(MIN(height), 0), ROUND(COALESCE(PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY height), 0)), COALESCE(AVG(height), 0), COALESCE(STDDEV_POP(height), 0), "+
+		histogramColumn(4)+", "+
+		"(SELECT COALESCE(JSON_AGG(JSON_BUILD_OBJECT('estate_id', id, 'tree_count', trees, 'max_height', max_height, 'density', trees::DOUBLE PRECISION / plots) ORDER BY max_height DESC, trees DESC, id), '[]') FROM (SELECT * FROM per_estate WHERE trees > 0 ORDER BY max_height DESC, trees DESC, id LIMIT $5) AS tallest), "+
+		"(SELECT COALESCE(JSON_AGG(JSON_BUILD_OBJECT('estate_id', id, 'tree_count', trees, 'max_height', max_height, 'density', trees::DOUBLE PRECISION / plots) ORDER BY trees::DOUBLE PRECISION / plots DESC, id), '[]') FROM (SELECT * FROM per_estate WHERE trees > 0 ORDER BY trees::DOUBLE PRECISION / plots DESC, id LIMIT $5) AS densest) "+
+		"FROM heights", estateIds, filter.CreatedFrom, filter.CreatedTo, bucketWidth, top).
+		Scan(&stats.EstateCount, &stats.TotalPlots, &stats.TreeCount, &stats.Heights.MaxHeight, &stats.Heights.MinHeight, &stats.Heights.MedianHeight, &stats.Heights.MeanHeight, &stats.Heights.StdDevHeight, &histogram, &tallest, &densest)
 	if err != nil {
 		return stats, err
 	}
 
+	if err = json.Unmarshal(histogram, &stats.Heights.Histogram); err != nil {
+		return stats, err
+	}
+	if err = json.Unmarshal(tallest, &stats.TallestEstates); err != nil {
+		return stats, err
+	}
+	if err = json.Unmarshal(densest, &stats.DensestEstates); err != nil {
+		return stats, err
+	}
+
 	return stats, nil
 }
 
-func (r *Repository) GetDronePlanByEstateId(estateId string) (plan DronePlan, err error) {
-	err = r.Db.QueryRow("SELECT distance FROM drone_plans WHERE estate_id = $1", estateId).Scan(&plan.Distance)
+func (r *Repository) GetTreesByEstateId(ctx context.Context, estateId string) (trees []Tree, err error) {
+	defer translate(&err, nil)
+
+	rows, err := r.db().QueryContext(ctx, "SELECT id, x_coordinate, y_coordinate, height FROM trees WHERE estate_id = $1 AND deleted_at IS NULL", estateId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tree Tree
+		if err = rows.Scan(&tree.Id, &tree.X, &tree.Y, &tree.Height); err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+	return trees, rows.Err()
+}
+
+// ListTrees returns a page of the trees of an estate matching the filter,
+// ordered row by row.
+func (r *Repository) ListTrees(ctx context.Context, estateId string, filter TreeFilter, offset, limit int) (trees []Tree, err error) {
+	defer translate(&err, nil)
+
+	query := "SELECT " + treeColumns + " FROM trees WHERE estate_id = $1 AND deleted_at IS NULL"
+	args := []any{estateId}
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		query += fmt.Sprintf(" AND "+condition, len(args))
+	}
+
+	if filter.MinHeight != nil {
+		where("height >= $%d", *filter.MinHeight)
+	}
+	if filter.MaxHeight != nil {
+		where("height <= $%d", *filter.MaxHeight)
+	}
+	if filter.Bounds != nil {
+		where("x_coordinate >= $%d", filter.Bounds.X1)
+		where("y_coordinate >= $%d", filter.Bounds.Y1)
+		where("x_coordinate <= $%d", filter.Bounds.X2)
+		where("y_coordinate <= $%d", filter.Bounds.Y2)
+	}
+
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY y_coordinate, x_coordinate LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tree Tree
+		if err = scanTree(rows, &tree); err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+	return trees, rows.Err()
+}
+
+func (r *Repository) GetTreeById(ctx context.Context, estateId, treeId string) (tree Tree, err error) {
+	defer translate(&err, ErrTreeNotFound)
+
+	err = scanTree(r.db().QueryRowContext(ctx, "SELECT "+treeColumns+" FROM trees WHERE id = $1 AND estate_id = $2 AND deleted_at IS NULL", treeId, estateId), &tree)
+	return tree, err
+}
+
+// StreamTrees calls visit for every tree of an estate, ordered row by row, as
+// they are read from the database, so that they are never all held in memory.
+// It stops at the first error returned by visit.
+func (r *Repository) StreamTrees(ctx context.Context, estateId string, visit func(tree Tree) error) (err error) {
+	// The errors of visit are returned as they are, only the ones of the
+	// database are translated
+	rows, err := r.db().QueryContext(ctx, "SELECT "+treeColumns+" FROM trees WHERE estate_id = $1 AND deleted_at IS NULL ORDER BY y_coordinate, x_coordinate", estateId)
+	if err != nil {
+		translate(&err, nil)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tree Tree
+		if err = scanTree(rows, &tree); err != nil {
+			translate(&err, nil)
+			return err
+		}
+		if err = visit(tree); err != nil {
+			return err
+		}
+	}
+	err = rows.Err()
+	translate(&err, nil)
+	return err
+}
+
+// treeColumns are the tree columns scanned by scanTree.
+const treeColumns = "id, x_coordinate, y_coordinate, height, created_at, updated_at"
+
+func scanTree(row rowScanner, tree *Tree) error {
+	return row.Scan(&tree.Id, &tree.X, &tree.Y, &tree.Height, &tree.CreatedAt, &tree.UpdatedAt)
+}
+
+func (r *Repository) GetDronePlanByEstateId(ctx context.Context, estateId string) (plan DronePlan, err error) {
+	defer translate(&err, ErrDronePlanNotFound)
+
+	err = r.db().QueryRowContext(ctx, "SELECT distance FROM drone_plans WHERE estate_id = $1", estateId).Scan(&plan.Distance)
 	if err != nil {
 		return plan, err
 	}
 	return plan, nil
 }
+
+// SaveDronePlan stores the drone plan of an estate without a stored one. A
+// stored plan is kept up to date by the tree writes, so it is never
+// overwritten. The plan must be computed within the unit of work that locked
+// the estate, see LockEstate.
+func (r *Repository) SaveDronePlan(ctx context.Context, estateId string, plan DronePlan) (err error) {
+	defer translate(&err, ErrEstateNotFound)
+
+	_, err = r.db().ExecContext(ctx, "INSERT INTO drone_plans (estate_id, distance) VALUES ($1, $2) ON CONFLICT (estate_id) DO NOTHING", estateId, plan.Distance)
+	return err
+}
+
+func (r *Repository) GetDroneConfigByEstateId(ctx context.Context, estateId string) (config DroneConfig, err error) {
+	defer translate(&err, ErrDroneConfigNotFound)
+
+	err = r.db().QueryRowContext(ctx, "SELECT plot_size, clearance, takeoff_altitude FROM drone_configs WHERE estate_id = $1", estateId).Scan(&config.PlotSize, &config.Clearance, &config.TakeoffAltitude)
+	if err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// SaveDroneConfig also drops the cached drone plan of the estate, since it was
+// computed with the previous flight parameters.
+func (r *Repository) SaveDroneConfig(ctx context.Context, estateId string, config DroneConfig) (err error) {
+	defer translate(&err, ErrEstateNotFound)
+
+	tx, err := r.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id string
+	if err = tx.QueryRowContext(ctx, "SELECT id FROM estates WHERE id = $1 FOR SHARE", estateId).Scan(&id); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "WITH invalidated AS (DELETE FROM drone_plans WHERE estate_id = $1) INSERT INTO drone_configs (estate_id, plot_size, clearance, takeoff_altitude) VALUES ($1, $2, $3, $4) ON CONFLICT (estate_id) DO UPDATE SET plot_size = EXCLUDED.plot_size, clearance = EXCLUDED.clearance, takeoff_altitude = EXCLUDED.takeoff_altitude, updated_at = NOW()", estateId, config.PlotSize, config.Clearance, config.TakeoffAltitude)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateZone returns ErrPlotOutOfBounds when the zone does not fit in the
+// estate, ErrZoneOverlaps when it overlaps another zone, and ErrZoneNameTaken
+// when its name is already taken within the estate.
+func (r *Repository) CreateZone(ctx context.Context, estateId string, zone Zone) (id string, err error) {
+	defer translate(&err, ErrEstateNotFound)
+
+	tx, err := r.begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Lock the estate so that zones are checked against each other one at a
+	// time, and against the bounds of a concurrent resize
+	var width, length int
+	if err = tx.QueryRowContext(ctx, "SELECT width, length FROM estates WHERE id = $1 FOR UPDATE", estateId).Scan(&width, &length); err != nil {
+		return "", err
+	}
+	if zone.X2 > width || zone.Y2 > length {
+		return "", ErrPlotOutOfBounds
+	}
+
+	var overlaps bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM zones WHERE estate_id = $1 AND x1 <= $4 AND x2 >= $2 AND y1 <= $5 AND y2 >= $3)", estateId, zone.X1, zone.Y1, zone.X2, zone.Y2).Scan(&overlaps)
+	if err != nil {
+		return "", err
+	}
+	if overlaps {
+		return "", ErrZoneOverlaps
+	}
+
+	err = tx.QueryRowContext(ctx, "INSERT INTO zones (estate_id, name, x1, y1, x2, y2) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id", estateId, zone.Name, zone.X1, zone.Y1, zone.X2, zone.Y2).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	return id, tx.Commit()
+}
+
+// zoneColumns are the zone columns scanned by scanZone.
+const zoneColumns = "id, name, x1, y1, x2, y2, created_at"
+
+func scanZone(row rowScanner, zone *Zone) error {
+	return row.Scan(&zone.Id, &zone.Name, &zone.X1, &zone.Y1, &zone.X2, &zone.Y2, &zone.CreatedAt)
+}
+
+// ListZones returns the zones of an estate ordered by name.
+func (r *Repository) ListZones(ctx context.Context, estateId string) (zones []Zone, err error) {
+	defer translate(&err, nil)
+
+	rows, err := r.db().QueryContext(ctx, "SELECT "+zoneColumns+" FROM zones WHERE estate_id = $1 ORDER BY name", estateId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var zone Zone
+		if err := scanZone(rows, &zone); err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	return zones, rows.Err()
+}
+
+// GetZoneById returns ErrZoneNotFound when the zone is not in the estate.
+func (r *Repository) GetZoneById(ctx context.Context, estateId, zoneId string) (zone Zone, err error) {
+	defer translate(&err, ErrZoneNotFound)
+
+	err = scanZone(r.db().QueryRowContext(ctx, "SELECT "+zoneColumns+" FROM zones WHERE id = $1 AND estate_id = $2", zoneId, estateId), &zone)
+	return zone, err
+}
+
+// DeleteZone returns ErrZoneNotFound when the zone is not in the estate. The
+// trees of the zone are kept.
+func (r *Repository) DeleteZone(ctx context.Context, estateId, zoneId string) (err error) {
+	defer translate(&err, ErrZoneNotFound)
+
+	result, err := r.db().ExecContext(ctx, "DELETE FROM zones WHERE id = $1 AND estate_id = $2", zoneId, estateId)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrZoneNotFound
+	}
+	return nil
+}
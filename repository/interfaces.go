@@ -4,30 +4,220 @@
 // interfaces using mockgen. See the Makefile for more information.
 package repository
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Estate struct {
-	Id     string
-	Width  int
-	Length int
+	Id           string        `json:"id"`
+	Width        int           `json:"width"`
+	Length       int           `json:"length"`
+	GeoReference *GeoReference `json:"geo_reference,omitempty"`
+	// TreeCount is only set by ListEstates, see CountTreesByEstateId
+	TreeCount int       `json:"tree_count"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
+// GeoReference places an estate on the globe, see planner.GeoReference.
+type GeoReference struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Bearing   float64 `json:"bearing"`
+}
+
+type Tree struct {
+	Id        string    `json:"id"`
+	X         int       `json:"x"`
+	Y         int       `json:"y"`
+	Height    int       `json:"height"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Sources of the measurements recorded by the repository itself.
+const (
+	SourcePlanting   = "planting"
+	SourceImport     = "import"
+	SourceCorrection = "correction"
+)
+
+// Measurement is a tree height measured at some point in time.
+type Measurement struct {
+	Id         string    `json:"id"`
+	Height     int       `json:"height"`
+	MeasuredAt time.Time `json:"measured_at"`
+	// Source tells where the measurement comes from, e.g. a survey
+	Source string `json:"source"`
+}
+
+// ImportedTree is the outcome of planting one tree of a bulk import.
+type ImportedTree struct {
+	// Id is empty when the tree was not planted
+	Id string
+	// Occupied is set when the plot already had a tree
+	Occupied bool
+}
+
+// TreeFilter narrows down ListTrees, nil fields match every tree.
+type TreeFilter struct {
+	MinHeight *int
+	MaxHeight *int
+	Bounds    *Bounds
+}
+
+// Bounds is the rectangle of plots from (X1, Y1) to (X2, Y2), both included.
+type Bounds struct {
+	X1 int `json:"x1"`
+	Y1 int `json:"y1"`
+	X2 int `json:"x2"`
+	Y2 int `json:"y2"`
+}
+
+// Zone is a named block of an estate, e.g. "Block A-3". The zones of an
+// estate do not overlap.
+type Zone struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Bounds
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EstateStats sums up the tree heights of an estate. The median is rounded to
+// the nearest meter.
 type EstateStats struct {
 	Count        int `json:"count"`
 	MaxHeight    int `json:"max"`
 	MinHeight    int `json:"min"`
 	MedianHeight int `json:"median"`
+	// The fields below are only set when asked for in StatsOptions
+	MeanHeight   *float64 `json:"mean,omitempty"`
+	StdDevHeight *float64 `json:"stddev,omitempty"`
+	// Percentiles maps p10, p90... to the height below which that percentage
+	// of the trees falls
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+	Histogram   []HistogramBucket  `json:"histogram,omitempty"`
+	Density     *Density           `json:"density,omitempty"`
+}
+
+// StatsOptions picks the stats computed on top of count, max, min and median.
+type StatsOptions struct {
+	// Bounds restricts the stats to the trees within, nil for the whole estate
+	Bounds *Bounds
+	Mean   bool
+	StdDev bool
+	// Percentiles are percentages within 0 to 100
+	Percentiles []float64
+	// BucketWidth is the height range of the histogram buckets in meters,
+	// 0 for no histogram
+	BucketWidth int
+	Density     bool
+}
+
+// HistogramBucket counts the trees from From meters high up to To, excluded.
+type HistogramBucket struct {
+	From  int `json:"from"`
+	To    int `json:"to"`
+	Count int `json:"count"`
+}
+
+// PortfolioFilter narrows down GetPortfolioStats, nil fields match every
+// estate.
+type PortfolioFilter struct {
+	EstateIds   []string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// PortfolioStats sums up a set of estates.
+type PortfolioStats struct {
+	EstateCount int `json:"estate_count"`
+	TreeCount   int `json:"tree_count"`
+	// TotalPlots is the area of the estates in plots
+	TotalPlots     int64           `json:"total_plots"`
+	Heights        HeightStats     `json:"heights"`
+	TallestEstates []EstateRanking `json:"tallest_estates"`
+	DensestEstates []EstateRanking `json:"densest_estates"`
+}
+
+// HeightStats is the height distribution of the trees of several estates.
+// The median is rounded to the nearest meter.
+type HeightStats struct {
+	MaxHeight    int               `json:"max"`
+	MinHeight    int               `json:"min"`
+	MedianHeight int               `json:"median"`
+	MeanHeight   float64           `json:"mean"`
+	StdDevHeight float64           `json:"stddev"`
+	Histogram    []HistogramBucket `json:"histogram"`
+}
+
+// EstateRanking places an estate within a portfolio. Estates without trees
+// are never ranked.
+type EstateRanking struct {
+	EstateId  string `json:"estate_id"`
+	TreeCount int    `json:"tree_count"`
+	MaxHeight int    `json:"max_height"`
+	// Density is the number of trees per plot
+	Density float64 `json:"density"`
+}
+
+// Density tells how crowded an estate, or the bounds of StatsOptions, is.
+// Hectares follow the plot size of the drone config of the estate.
+type Density struct {
+	PerPlot    float64 `json:"per_plot"`
+	PerHectare float64 `json:"per_hectare"`
 }
 
 type DronePlan struct {
-	Distance int `json:"distance"`
+	Distance int        `json:"distance"`
+	Rest     *RestPoint `json:"rest,omitempty"`
+	// NaiveDistance is set on smooth plans, to compare with the distance of
+	// the drone returning to the cruise altitude over every plot
+	NaiveDistance *int `json:"naive_distance,omitempty"`
+}
+
+// RestPoint is the plot where the drone lands when its battery runs out.
+type RestPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// DroneConfig holds the flight parameters of an estate, in meters.
+type DroneConfig struct {
+	PlotSize        int `json:"plot_size"`
+	Clearance       int `json:"clearance"`
+	TakeoffAltitude int `json:"takeoff_altitude"`
 }
 
 type RepositoryInterface interface {
 	GetTestById(ctx context.Context, input GetTestByIdInput) (output GetTestByIdOutput, err error)
-	CreateEstate(width, length int) (id string, err error)
-	AddTree(estateId string, x, y, height int) (id string, err error)
-	GetEstateById(id string) (estate Estate, err error)
-	GetEstateStatsById(estateId string) (stats EstateStats, err error)
-	GetDronePlanByEstateId(estateId string) (plan DronePlan, err error)
+	WithTx(ctx context.Context, opts TxOptions, fn func(repo RepositoryInterface) error) (err error)
+	CreateEstate(ctx context.Context, width, length int, geo *GeoReference) (id string, err error)
+	AddTree(ctx context.Context, estateId string, x, y, height int) (id string, err error)
+	GetEstateById(ctx context.Context, id string) (estate Estate, err error)
+	LockEstate(ctx context.Context, id string) (estate Estate, err error)
+	ListEstates(ctx context.Context, offset, limit int, ascending bool) (estates []Estate, err error)
+	UpdateEstate(ctx context.Context, estate Estate) (err error)
+	DeleteEstate(ctx context.Context, id string) (err error)
+	CountTreesByEstateId(ctx context.Context, estateId string) (count int, err error)
+	GetEstateStatsById(ctx context.Context, estateId string, options StatsOptions) (stats EstateStats, err error)
+	GetEstateStatsAsOf(ctx context.Context, estateId string, asOf time.Time, options StatsOptions) (stats EstateStats, err error)
+	GetTreesByEstateId(ctx context.Context, estateId string) (trees []Tree, err error)
+	ListTrees(ctx context.Context, estateId string, filter TreeFilter, offset, limit int) (trees []Tree, err error)
+	GetTreeById(ctx context.Context, estateId, treeId string) (tree Tree, err error)
+	UpdateTree(ctx context.Context, estateId string, tree Tree) (updated Tree, err error)
+	StreamTrees(ctx context.Context, estateId string, visit func(tree Tree) error) (err error)
+	ImportTrees(ctx context.Context, estateId string, trees []Tree, atomic bool) (imported []ImportedTree, err error)
+	DeleteTree(ctx context.Context, estateId, treeId string) (err error)
+	RecordMeasurement(ctx context.Context, estateId, treeId string, measurement Measurement) (recorded Measurement, err error)
+	GetMeasurementsByTreeId(ctx context.Context, estateId, treeId string) (measurements []Measurement, err error)
+	GetPortfolioStats(ctx context.Context, filter PortfolioFilter, top, bucketWidth int) (stats PortfolioStats, err error)
+	CreateZone(ctx context.Context, estateId string, zone Zone) (id string, err error)
+	ListZones(ctx context.Context, estateId string) (zones []Zone, err error)
+	GetZoneById(ctx context.Context, estateId, zoneId string) (zone Zone, err error)
+	DeleteZone(ctx context.Context, estateId, zoneId string) (err error)
+	GetDronePlanByEstateId(ctx context.Context, estateId string) (plan DronePlan, err error)
+	SaveDronePlan(ctx context.Context, estateId string, plan DronePlan) (err error)
+	GetDroneConfigByEstateId(ctx context.Context, estateId string) (config DroneConfig, err error)
+	SaveDroneConfig(ctx context.Context, estateId string, config DroneConfig) (err error)
 }
@@ -7,6 +7,7 @@ package repository
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,78 +36,210 @@ func (m *MockRepositoryInterface) EXPECT() *MockRepositoryInterfaceMockRecorder
 }
 
 // AddTree mocks base method.
-func (m *MockRepositoryInterface) AddTree(estateId string, x, y, height int) (string, error) {
+func (m *MockRepositoryInterface) AddTree(ctx context.Context, estateId string, x, y, height int) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddTree", estateId, x, y, height)
+	ret := m.ctrl.Call(m, "AddTree", ctx, estateId, x, y, height)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddTree indicates an expected call of AddTree.
-func (mr *MockRepositoryInterfaceMockRecorder) AddTree(estateId, x, y, height interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) AddTree(ctx, estateId, x, y, height interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTree", reflect.TypeOf((*MockRepositoryInterface)(nil).AddTree), estateId, x, y, height)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTree", reflect.TypeOf((*MockRepositoryInterface)(nil).AddTree), ctx, estateId, x, y, height)
+}
+
+// CountTreesByEstateId mocks base method.
+func (m *MockRepositoryInterface) CountTreesByEstateId(ctx context.Context, estateId string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountTreesByEstateId", ctx, estateId)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountTreesByEstateId indicates an expected call of CountTreesByEstateId.
+func (mr *MockRepositoryInterfaceMockRecorder) CountTreesByEstateId(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountTreesByEstateId", reflect.TypeOf((*MockRepositoryInterface)(nil).CountTreesByEstateId), ctx, estateId)
 }
 
 // CreateEstate mocks base method.
-func (m *MockRepositoryInterface) CreateEstate(width, length int) (string, error) {
+func (m *MockRepositoryInterface) CreateEstate(ctx context.Context, width, length int, geo *GeoReference) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateEstate", width, length)
+	ret := m.ctrl.Call(m, "CreateEstate", ctx, width, length, geo)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateEstate indicates an expected call of CreateEstate.
-func (mr *MockRepositoryInterfaceMockRecorder) CreateEstate(width, length interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) CreateEstate(ctx, width, length, geo interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEstate", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateEstate), ctx, width, length, geo)
+}
+
+// CreateZone mocks base method.
+func (m *MockRepositoryInterface) CreateZone(ctx context.Context, estateId string, zone Zone) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateZone", ctx, estateId, zone)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateZone indicates an expected call of CreateZone.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateZone(ctx, estateId, zone interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateZone", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateZone), ctx, estateId, zone)
+}
+
+// DeleteEstate mocks base method.
+func (m *MockRepositoryInterface) DeleteEstate(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteEstate", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteEstate indicates an expected call of DeleteEstate.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteEstate(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEstate", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateEstate), width, length)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEstate", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteEstate), ctx, id)
+}
+
+// DeleteTree mocks base method.
+func (m *MockRepositoryInterface) DeleteTree(ctx context.Context, estateId, treeId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTree", ctx, estateId, treeId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTree indicates an expected call of DeleteTree.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteTree(ctx, estateId, treeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTree", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteTree), ctx, estateId, treeId)
+}
+
+// DeleteZone mocks base method.
+func (m *MockRepositoryInterface) DeleteZone(ctx context.Context, estateId, zoneId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteZone", ctx, estateId, zoneId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteZone indicates an expected call of DeleteZone.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteZone(ctx, estateId, zoneId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteZone", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteZone), ctx, estateId, zoneId)
+}
+
+// GetDroneConfigByEstateId mocks base method.
+func (m *MockRepositoryInterface) GetDroneConfigByEstateId(ctx context.Context, estateId string) (DroneConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDroneConfigByEstateId", ctx, estateId)
+	ret0, _ := ret[0].(DroneConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDroneConfigByEstateId indicates an expected call of GetDroneConfigByEstateId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetDroneConfigByEstateId(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDroneConfigByEstateId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetDroneConfigByEstateId), ctx, estateId)
 }
 
 // GetDronePlanByEstateId mocks base method.
-func (m *MockRepositoryInterface) GetDronePlanByEstateId(estateId string) (DronePlan, error) {
+func (m *MockRepositoryInterface) GetDronePlanByEstateId(ctx context.Context, estateId string) (DronePlan, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDronePlanByEstateId", estateId)
+	ret := m.ctrl.Call(m, "GetDronePlanByEstateId", ctx, estateId)
 	ret0, _ := ret[0].(DronePlan)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetDronePlanByEstateId indicates an expected call of GetDronePlanByEstateId.
-func (mr *MockRepositoryInterfaceMockRecorder) GetDronePlanByEstateId(estateId interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) GetDronePlanByEstateId(ctx, estateId interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDronePlanByEstateId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetDronePlanByEstateId), estateId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDronePlanByEstateId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetDronePlanByEstateId), ctx, estateId)
 }
 
 // GetEstateById mocks base method.
-func (m *MockRepositoryInterface) GetEstateById(id string) (Estate, error) {
+func (m *MockRepositoryInterface) GetEstateById(ctx context.Context, id string) (Estate, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEstateById", id)
+	ret := m.ctrl.Call(m, "GetEstateById", ctx, id)
 	ret0, _ := ret[0].(Estate)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetEstateById indicates an expected call of GetEstateById.
-func (mr *MockRepositoryInterfaceMockRecorder) GetEstateById(id interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) GetEstateById(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEstateById), ctx, id)
+}
+
+// GetEstateStatsAsOf mocks base method.
+func (m *MockRepositoryInterface) GetEstateStatsAsOf(ctx context.Context, estateId string, asOf time.Time, options StatsOptions) (EstateStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEstateStatsAsOf", ctx, estateId, asOf, options)
+	ret0, _ := ret[0].(EstateStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEstateStatsAsOf indicates an expected call of GetEstateStatsAsOf.
+func (mr *MockRepositoryInterfaceMockRecorder) GetEstateStatsAsOf(ctx, estateId, asOf, options interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEstateById), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateStatsAsOf", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEstateStatsAsOf), ctx, estateId, asOf, options)
 }
 
 // GetEstateStatsById mocks base method.
-func (m *MockRepositoryInterface) GetEstateStatsById(estateId string) (EstateStats, error) {
+func (m *MockRepositoryInterface) GetEstateStatsById(ctx context.Context, estateId string, options StatsOptions) (EstateStats, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEstateStatsById", estateId)
+	ret := m.ctrl.Call(m, "GetEstateStatsById", ctx, estateId, options)
 	ret0, _ := ret[0].(EstateStats)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetEstateStatsById indicates an expected call of GetEstateStatsById.
-func (mr *MockRepositoryInterfaceMockRecorder) GetEstateStatsById(estateId interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) GetEstateStatsById(ctx, estateId, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateStatsById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEstateStatsById), ctx, estateId, options)
+}
+
+// GetMeasurementsByTreeId mocks base method.
+func (m *MockRepositoryInterface) GetMeasurementsByTreeId(ctx context.Context, estateId, treeId string) ([]Measurement, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMeasurementsByTreeId", ctx, estateId, treeId)
+	ret0, _ := ret[0].([]Measurement)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMeasurementsByTreeId indicates an expected call of GetMeasurementsByTreeId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetMeasurementsByTreeId(ctx, estateId, treeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMeasurementsByTreeId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetMeasurementsByTreeId), ctx, estateId, treeId)
+}
+
+// GetPortfolioStats mocks base method.
+func (m *MockRepositoryInterface) GetPortfolioStats(ctx context.Context, filter PortfolioFilter, top, bucketWidth int) (PortfolioStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPortfolioStats", ctx, filter, top, bucketWidth)
+	ret0, _ := ret[0].(PortfolioStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPortfolioStats indicates an expected call of GetPortfolioStats.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPortfolioStats(ctx, filter, top, bucketWidth interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateStatsById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEstateStatsById), estateId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPortfolioStats", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPortfolioStats), ctx, filter, top, bucketWidth)
 }
 
 // GetTestById mocks base method.
@@ -123,3 +256,223 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetTestById(ctx, input interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTestById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTestById), ctx, input)
 }
+
+// GetTreeById mocks base method.
+func (m *MockRepositoryInterface) GetTreeById(ctx context.Context, estateId, treeId string) (Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTreeById", ctx, estateId, treeId)
+	ret0, _ := ret[0].(Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTreeById indicates an expected call of GetTreeById.
+func (mr *MockRepositoryInterfaceMockRecorder) GetTreeById(ctx, estateId, treeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTreeById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTreeById), ctx, estateId, treeId)
+}
+
+// GetTreesByEstateId mocks base method.
+func (m *MockRepositoryInterface) GetTreesByEstateId(ctx context.Context, estateId string) ([]Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTreesByEstateId", ctx, estateId)
+	ret0, _ := ret[0].([]Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTreesByEstateId indicates an expected call of GetTreesByEstateId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetTreesByEstateId(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTreesByEstateId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTreesByEstateId), ctx, estateId)
+}
+
+// GetZoneById mocks base method.
+func (m *MockRepositoryInterface) GetZoneById(ctx context.Context, estateId, zoneId string) (Zone, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetZoneById", ctx, estateId, zoneId)
+	ret0, _ := ret[0].(Zone)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetZoneById indicates an expected call of GetZoneById.
+func (mr *MockRepositoryInterfaceMockRecorder) GetZoneById(ctx, estateId, zoneId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetZoneById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetZoneById), ctx, estateId, zoneId)
+}
+
+// ImportTrees mocks base method.
+func (m *MockRepositoryInterface) ImportTrees(ctx context.Context, estateId string, trees []Tree, atomic bool) ([]ImportedTree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportTrees", ctx, estateId, trees, atomic)
+	ret0, _ := ret[0].([]ImportedTree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportTrees indicates an expected call of ImportTrees.
+func (mr *MockRepositoryInterfaceMockRecorder) ImportTrees(ctx, estateId, trees, atomic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportTrees", reflect.TypeOf((*MockRepositoryInterface)(nil).ImportTrees), ctx, estateId, trees, atomic)
+}
+
+// ListEstates mocks base method.
+func (m *MockRepositoryInterface) ListEstates(ctx context.Context, offset, limit int, ascending bool) ([]Estate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEstates", ctx, offset, limit, ascending)
+	ret0, _ := ret[0].([]Estate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEstates indicates an expected call of ListEstates.
+func (mr *MockRepositoryInterfaceMockRecorder) ListEstates(ctx, offset, limit, ascending interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEstates", reflect.TypeOf((*MockRepositoryInterface)(nil).ListEstates), ctx, offset, limit, ascending)
+}
+
+// ListTrees mocks base method.
+func (m *MockRepositoryInterface) ListTrees(ctx context.Context, estateId string, filter TreeFilter, offset, limit int) ([]Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTrees", ctx, estateId, filter, offset, limit)
+	ret0, _ := ret[0].([]Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTrees indicates an expected call of ListTrees.
+func (mr *MockRepositoryInterfaceMockRecorder) ListTrees(ctx, estateId, filter, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrees", reflect.TypeOf((*MockRepositoryInterface)(nil).ListTrees), ctx, estateId, filter, offset, limit)
+}
+
+// ListZones mocks base method.
+func (m *MockRepositoryInterface) ListZones(ctx context.Context, estateId string) ([]Zone, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListZones", ctx, estateId)
+	ret0, _ := ret[0].([]Zone)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListZones indicates an expected call of ListZones.
+func (mr *MockRepositoryInterfaceMockRecorder) ListZones(ctx, estateId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListZones", reflect.TypeOf((*MockRepositoryInterface)(nil).ListZones), ctx, estateId)
+}
+
+// LockEstate mocks base method.
+func (m *MockRepositoryInterface) LockEstate(ctx context.Context, id string) (Estate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockEstate", ctx, id)
+	ret0, _ := ret[0].(Estate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockEstate indicates an expected call of LockEstate.
+func (mr *MockRepositoryInterfaceMockRecorder) LockEstate(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockEstate", reflect.TypeOf((*MockRepositoryInterface)(nil).LockEstate), ctx, id)
+}
+
+// RecordMeasurement mocks base method.
+func (m *MockRepositoryInterface) RecordMeasurement(ctx context.Context, estateId, treeId string, measurement Measurement) (Measurement, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordMeasurement", ctx, estateId, treeId, measurement)
+	ret0, _ := ret[0].(Measurement)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordMeasurement indicates an expected call of RecordMeasurement.
+func (mr *MockRepositoryInterfaceMockRecorder) RecordMeasurement(ctx, estateId, treeId, measurement interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordMeasurement", reflect.TypeOf((*MockRepositoryInterface)(nil).RecordMeasurement), ctx, estateId, treeId, measurement)
+}
+
+// UpdateEstate mocks base method.
+func (m *MockRepositoryInterface) UpdateEstate(ctx context.Context, estate Estate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEstate", ctx, estate)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateEstate indicates an expected call of UpdateEstate.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateEstate(ctx, estate interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEstate", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateEstate), ctx, estate)
+}
+
+// SaveDroneConfig mocks base method.
+func (m *MockRepositoryInterface) SaveDroneConfig(ctx context.Context, estateId string, config DroneConfig) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDroneConfig", ctx, estateId, config)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveDroneConfig indicates an expected call of SaveDroneConfig.
+func (mr *MockRepositoryInterfaceMockRecorder) SaveDroneConfig(ctx, estateId, config interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDroneConfig", reflect.TypeOf((*MockRepositoryInterface)(nil).SaveDroneConfig), ctx, estateId, config)
+}
+
+// SaveDronePlan mocks base method.
+func (m *MockRepositoryInterface) SaveDronePlan(ctx context.Context, estateId string, plan DronePlan) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDronePlan", ctx, estateId, plan)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveDronePlan indicates an expected call of SaveDronePlan.
+func (mr *MockRepositoryInterfaceMockRecorder) SaveDronePlan(ctx, estateId, plan interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDronePlan", reflect.TypeOf((*MockRepositoryInterface)(nil).SaveDronePlan), ctx, estateId, plan)
+}
+
+// StreamTrees mocks base method.
+func (m *MockRepositoryInterface) StreamTrees(ctx context.Context, estateId string, visit func(Tree) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamTrees", ctx, estateId, visit)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamTrees indicates an expected call of StreamTrees.
+func (mr *MockRepositoryInterfaceMockRecorder) StreamTrees(ctx, estateId, visit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamTrees", reflect.TypeOf((*MockRepositoryInterface)(nil).StreamTrees), ctx, estateId, visit)
+}
+
+// UpdateTree mocks base method.
+func (m *MockRepositoryInterface) UpdateTree(ctx context.Context, estateId string, tree Tree) (Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTree", ctx, estateId, tree)
+	ret0, _ := ret[0].(Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTree indicates an expected call of UpdateTree.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateTree(ctx, estateId, tree interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTree", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateTree), ctx, estateId, tree)
+}
+
+// WithTx mocks base method.
+func (m *MockRepositoryInterface) WithTx(ctx context.Context, opts TxOptions, fn func(RepositoryInterface) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", ctx, opts, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockRepositoryInterfaceMockRecorder) WithTx(ctx, opts, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockRepositoryInterface)(nil).WithTx), ctx, opts, fn)
+}
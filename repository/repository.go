@@ -2,13 +2,18 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Repository struct {
 	Db *sql.DB
+	// tx is the transaction of the unit of work run by WithTx, if any
+	tx *sql.Tx
 }
 
 type NewRepositoryOptions struct {
@@ -24,3 +29,129 @@ func NewRepository(opts NewRepositoryOptions) *Repository {
 		Db: db,
 	}
 }
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// db is where the queries go: the transaction of the unit of work, or the
+// database itself.
+func (r *Repository) db() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.Db
+}
+
+// transaction is the transaction of a method writing several rows at once.
+// Within a unit of work it is a savepoint of the transaction of the unit of
+// work instead, so that the method still succeeds or fails as a whole.
+type transaction struct {
+	*sql.Tx
+	ctx       context.Context
+	savepoint bool
+	// done is set once the savepoint is released or rolled back
+	done *bool
+}
+
+func (r *Repository) begin(ctx context.Context) (transaction, error) {
+	if r.tx != nil {
+		_, err := r.tx.ExecContext(ctx, "SAVEPOINT repository_method")
+		return transaction{Tx: r.tx, ctx: ctx, savepoint: true, done: new(bool)}, err
+	}
+
+	tx, err := r.Db.BeginTx(ctx, nil)
+	return transaction{Tx: tx}, err
+}
+
+func (t transaction) Commit() error {
+	if !t.savepoint {
+		return t.Tx.Commit()
+	}
+
+	*t.done = true
+	_, err := t.Tx.ExecContext(t.ctx, "RELEASE SAVEPOINT repository_method")
+	return err
+}
+
+func (t transaction) Rollback() error {
+	if !t.savepoint {
+		return t.Tx.Rollback()
+	}
+	if *t.done {
+		return sql.ErrTxDone
+	}
+
+	*t.done = true
+	_, err := t.Tx.ExecContext(t.ctx, "ROLLBACK TO SAVEPOINT repository_method")
+	return err
+}
+
+// TxOptions configures a unit of work run by WithTx.
+type TxOptions struct {
+	// Isolation is the isolation level of the transaction, the default one
+	// of the database when left out, i.e. read committed
+	Isolation sql.IsolationLevel
+	// MaxRetries is how many times the unit of work is run again when
+	// Postgres aborts it on a serialization failure or a deadlock
+	MaxRetries int
+}
+
+// WithTx runs fn as a unit of work: the repository given to fn runs every
+// query in a single transaction, which is committed when fn returns nil and
+// rolled back otherwise. fn must only use the repository it is given, and
+// may be run several times when opts allows for retries. It returns
+// ErrConflict when the unit of work still conflicts with a concurrent one
+// after the retries. Within a unit of work, WithTx runs fn in the same
+// transaction.
+func (r *Repository) WithTx(ctx context.Context, opts TxOptions, fn func(repo RepositoryInterface) error) (err error) {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = r.runTx(ctx, opts, fn)
+		if attempt >= opts.MaxRetries || !retryable(err) {
+			return err
+		}
+
+		// Back off a little, so that the conflicting transaction completes
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * 10 * time.Millisecond):
+		}
+	}
+}
+
+func (r *Repository) runTx(ctx context.Context, opts TxOptions, fn func(repo RepositoryInterface) error) (err error) {
+	tx, err := r.Db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation})
+	if err != nil {
+		translate(&err, nil)
+		return err
+	}
+	defer tx.Rollback()
+
+	// The errors of fn are returned as they are, the repository given to fn
+	// translates its own
+	if err = fn(&Repository{Db: r.Db, tx: tx}); err != nil {
+		return err
+	}
+	err = tx.Commit()
+	translate(&err, nil)
+	return err
+}
+
+// retryable tells whether a unit of work was aborted by Postgres for running
+// concurrently with another one, and may succeed when run again.
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01" // serialization_failure, deadlock_detected
+}
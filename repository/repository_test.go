@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDB is a database that logs the transactions and the statements run on
+// it. Its queries all fail with queryErr, and its commits fail with
+// commitErrs in turn, then succeed.
+type fakeDB struct {
+	mu         sync.Mutex
+	log        []string
+	queryErr   error
+	commitErrs []error
+}
+
+func (db *fakeDB) record(entry string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.log = append(db.log, entry)
+}
+
+func (db *fakeDB) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{db: db}, nil
+}
+
+func (db *fakeDB) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.record("BEGIN " + sql.IsolationLevel(opts.Isolation).String())
+	return &fakeTx{db: c.db}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return nil, c.db.queryErr
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.db.record("COMMIT")
+
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	if len(tx.db.commitErrs) == 0 {
+		return nil
+	}
+	err := tx.db.commitErrs[0]
+	tx.db.commitErrs = tx.db.commitErrs[1:]
+	return err
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.record("ROLLBACK")
+	return nil
+}
+
+func TestWithTx_RetriesSerializationFailure(t *testing.T) {
+	db := &fakeDB{commitErrs: []error{&pq.Error{Code: "40001"}}}
+	repo := &Repository{Db: sql.OpenDB(db)}
+
+	attempts := 0
+	err := repo.WithTx(context.Background(), TxOptions{Isolation: sql.LevelSerializable, MaxRetries: 3}, func(repo RepositoryInterface) error {
+		attempts++
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, []string{"BEGIN Serializable", "COMMIT", "BEGIN Serializable", "COMMIT"}, db.log)
+}
+
+func TestWithTx_ConflictAfterRetries(t *testing.T) {
+	db := &fakeDB{commitErrs: []error{&pq.Error{Code: "40001"}, &pq.Error{Code: "40P01"}, &pq.Error{Code: "40001"}}}
+	repo := &Repository{Db: sql.OpenDB(db)}
+
+	attempts := 0
+	err := repo.WithTx(context.Background(), TxOptions{Isolation: sql.LevelSerializable, MaxRetries: 2}, func(repo RepositoryInterface) error {
+		attempts++
+		return nil
+	})
+
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestWithTx_RollsBackOtherErrors(t *testing.T) {
+	db := &fakeDB{}
+	repo := &Repository{Db: sql.OpenDB(db)}
+
+	failed := errors.New("failed")
+	attempts := 0
+	err := repo.WithTx(context.Background(), TxOptions{MaxRetries: 3}, func(repo RepositoryInterface) error {
+		attempts++
+		return failed
+	})
+
+	assert.ErrorIs(t, err, failed)
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, []string{"BEGIN Default", "ROLLBACK"}, db.log)
+}
+
+func TestWithTx_RollsBackFailedMethodToSavepoint(t *testing.T) {
+	db := &fakeDB{queryErr: &pq.Error{Code: "57014"}}
+	repo := &Repository{Db: sql.OpenDB(db)}
+
+	// The unit of work goes on, and is committed, without the tree
+	err := repo.WithTx(context.Background(), TxOptions{}, func(repo RepositoryInterface) error {
+		_, err := repo.AddTree(context.Background(), "1", 1, 1, 10)
+		assert.ErrorIs(t, err, ErrTimeout)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN Default", "SAVEPOINT repository_method", "ROLLBACK TO SAVEPOINT repository_method", "COMMIT"}, db.log)
+}
@@ -121,6 +121,38 @@ func getTestCases() []TestCase {
 				},
 			},
 		},
+		{
+			Name: "Test Bulk Import",
+			Steps: []TestCaseStep{
+				{
+					Request: SendRequestNewEstate(10, 20),
+					Expect:  ExpectNewEstateOk(),
+				},
+				{
+					Request: SendRequestBulkTrees("/trees/bulk"),
+					Expect:  ExpectBulkTreesOk(1),
+				},
+			},
+		},
+		{
+			// Only /trees/bulk is routed to the import, not any route
+			// beginning with /trees
+			Name: "Test Error: Bulk Import Route With Suffix",
+			Steps: []TestCaseStep{
+				{
+					Request: SendRequestNewEstate(10, 20),
+					Expect:  ExpectNewEstateOk(),
+				},
+				{
+					Request: SendRequestBulkTrees("/trees:bulk"),
+					Expect:  ExpectNotFound(),
+				},
+				{
+					Request: SendRequestBulkTrees("/treesbulk"),
+					Expect:  ExpectNotFound(),
+				},
+			},
+		},
 		CreateNormalTestCase("Normal 1", []any{
 			[]any{CreateEstate, 10, 20},
 			[]any{CreateTree, 10, 5, 5},
@@ -134,6 +166,13 @@ func getTestCases() []TestCase {
 			[]any{GetStats, 3, 10, 20, 10},
 			[]any{GetDronePlan, 0, 82},
 		}),
+		// The median of an even number of trees is rounded
+		CreateNormalTestCase("Normal 3", []any{
+			[]any{CreateEstate, 5, 1},
+			[]any{CreateTree, 5, 1, 2},
+			[]any{CreateTree, 10, 1, 3},
+			[]any{GetStats, 2, 5, 10, 8},
+		}),
 	}
 }
 
@@ -247,6 +286,21 @@ func ExpectNewTreeOk() ExpectFunc {
 	}
 }
 
+func SendRequestBulkTrees(path string) RequestFunc {
+	return func(t *testing.T, ctx context.Context, tc *TestCase) (*http.Request, error) {
+		id := tc.Steps[0].Result["id"].(string)
+		body := `[{"x": 1, "y": 1, "height": 10}]`
+		return http.NewRequest("POST", ApiUrl+"/estate/"+id+path, bytes.NewReader([]byte(body)))
+	}
+}
+
+func ExpectBulkTreesOk(accepted int) ExpectFunc {
+	return func(t *testing.T, ctx context.Context, tc *TestCase, resp *http.Response, data map[string]any) {
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.Equal(t, accepted, int(data["accepted"].(float64)))
+	}
+}
+
 func SendRequestGetStats() RequestFunc {
 	return func(t *testing.T, ctx context.Context, tc *TestCase) (*http.Request, error) {
 		id := tc.Steps[0].Result["id"].(string)
@@ -299,6 +353,12 @@ func RequireDistance(t *testing.T, resp *http.Response, data map[string]any, dis
 	require.Equal(t, distance, int(data["distance"].(float64)))
 }
 
+func ExpectNotFound() ExpectFunc {
+	return func(t *testing.T, ctx context.Context, tc *TestCase, resp *http.Response, data map[string]any) {
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+}
+
 func ExpectBadRequest() ExpectFunc {
 	return func(t *testing.T, ctx context.Context, tc *TestCase, resp *http.Response, data map[string]any) {
 		require.Equal(t, http.StatusBadRequest, resp.StatusCode)